@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+type (
+	// Provider Generic OpenID Connect provider for goth, the endpoints are read from
+	// the discovery document of the issuer the first time they are needed ...
+	Provider struct {
+		ClientKey   string
+		Secret      string
+		CallbackURL string
+		Issuer      string
+		Scopes      []string
+		// Tenant Directory id of a Microsoft Entra tenant, when it is set the id
+		// token must be issued by and for that tenant ...
+		Tenant string
+		// EmailVerifiedClaim Claim that says the email is verified, email_verified
+		// unless the issuer uses another one such as xms_edov ...
+		EmailVerifiedClaim string
+		HTTPClient         *http.Client
+		providerName       string
+		mutex              sync.Mutex
+		config             *oauth2.Config
+		userInfoURL        string
+	}
+	// Session Data kept between starting and completing an OpenID Connect login ...
+	Session struct {
+		AuthURL      string
+		AccessToken  string
+		RefreshToken string
+		IDToken      string
+		ExpiresAt    time.Time
+	}
+	// discovery Fields of an OpenID Connect discovery document ...
+	discovery struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+)
+
+// NewOIDC Create an OpenID Connect provider for an issuer ...
+func NewOIDC(name string, issuer string, clientKey string, secret string, callbackURL string, scopes ...string) *Provider {
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		Scopes:       scopes,
+		providerName: name,
+	}
+}
+
+// Name ...
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName ...
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// Debug ...
+func (p *Provider) Debug(debug bool) {}
+
+// client HTTP client for calls to the issuer ...
+func (p *Provider) client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// discover Read the endpoints of the issuer once, a failed attempt is retried on
+// the next login so an issuer that is down at start up does not disable it ...
+func (p *Provider) discover() (*oauth2.Config, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.config != nil {
+		return p.config, nil
+	}
+	res, err := p.client().Get(p.Issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s discovery responded with a %d", p.providerName, res.StatusCode)
+	}
+	var doc discovery
+	if err = json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.UserInfoEndpoint == "" {
+		return nil, fmt.Errorf("%s discovery document is missing endpoints", p.providerName)
+	}
+	p.userInfoURL = doc.UserInfoEndpoint
+	p.config = &oauth2.Config{
+		ClientID:     p.ClientKey,
+		ClientSecret: p.Secret,
+		RedirectURL:  p.CallbackURL,
+		Endpoint:     oauth2.Endpoint{AuthURL: doc.AuthorizationEndpoint, TokenURL: doc.TokenEndpoint},
+		Scopes:       p.Scopes,
+	}
+	return p.config, nil
+}
+
+// BeginAuth Url of the issuer's login page ...
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	config, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{AuthURL: config.AuthCodeURL(state)}, nil
+}
+
+// UnmarshalSession ...
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	session := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(session)
+	return session, err
+}
+
+// claimBool Boolean claim that some issuers send as a string ...
+func claimBool(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// claimString ...
+func claimString(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// FetchUser Read the claims of the user from the userinfo endpoint ...
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		Provider:     p.Name(),
+		AccessToken:  sess.AccessToken,
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+	}
+	if user.AccessToken == "" {
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+	if _, err := p.discover(); err != nil {
+		return user, err
+	}
+	req, err := http.NewRequest("GET", p.userInfoURL, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	res, err := p.client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, res.StatusCode)
+	}
+	if err = json.NewDecoder(res.Body).Decode(&user.RawData); err != nil {
+		return user, err
+	}
+	claims := user.RawData
+	user.UserID = claimString(claims, "sub")
+	if user.UserID == "" {
+		return user, errors.New(p.providerName + " sent no subject for the user")
+	}
+	idClaims, err := p.idTokenClaims(sess.IDToken, user.UserID)
+	if err != nil {
+		return user, err
+	}
+	for name, value := range idClaims {
+		if _, ok := claims[name]; !ok {
+			claims[name] = value
+		}
+	}
+	user.Email = claimString(claims, "email")
+	user.Name = claimString(claims, "name")
+	user.NickName = claimString(claims, "preferred_username")
+	user.FirstName = claimString(claims, "given_name")
+	user.LastName = claimString(claims, "family_name")
+	user.AvatarURL = claimString(claims, "picture")
+	if user.FirstName == "" && user.Name != "" {
+		names := strings.SplitN(user.Name, " ", 2)
+		user.FirstName = names[0]
+		if len(names) > 1 {
+			user.LastName = names[1]
+		}
+	}
+	verifiedClaim := p.EmailVerifiedClaim
+	if verifiedClaim == "" {
+		verifiedClaim = "email_verified"
+	}
+	claims["email_verified"] = claimBool(claims[verifiedClaim])
+	return user, nil
+}
+
+// idTokenClaims Claims of the id token the token endpoint sent, it came straight
+// from the issuer over TLS so its signature is not checked. The token must be
+// issued by the issuer for this client and user, and for the tenant when there is
+// one. Issuers that send no id token are only accepted without a tenant ...
+func (p *Provider) idTokenClaims(idToken string, subject string) (map[string]interface{}, error) {
+	if idToken == "" {
+		if p.Tenant != "" {
+			return nil, errors.New(p.providerName + " sent no id token")
+		}
+		return nil, nil
+	}
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New(p.providerName + " sent an invalid id token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, errors.New(p.providerName + " sent an invalid id token")
+	}
+	var claims map[string]interface{}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New(p.providerName + " sent an invalid id token")
+	}
+	if claimString(claims, "iss") != p.Issuer {
+		return nil, fmt.Errorf("%s id token is from another issuer %q", p.providerName, claimString(claims, "iss"))
+	}
+	if !hasAudience(claims["aud"], p.ClientKey) {
+		return nil, errors.New(p.providerName + " id token is for another client")
+	}
+	if claimString(claims, "sub") != subject {
+		return nil, errors.New(p.providerName + " id token is for another user")
+	}
+	if p.Tenant != "" && !strings.EqualFold(claimString(claims, "tid"), p.Tenant) {
+		return nil, fmt.Errorf("%s id token is from another tenant %q", p.providerName, claimString(claims, "tid"))
+	}
+	return claims, nil
+}
+
+// hasAudience Whether the aud claim, one audience or a list, names the client ...
+func hasAudience(aud interface{}, clientKey string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientKey
+	case []interface{}:
+		for _, audience := range v {
+			if audience == clientKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RefreshTokenAvailable ...
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken Get a new access token with a refresh token ...
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	config, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	return config.TokenSource(goth.ContextForClient(p.client()), token).Token()
+}
+
+// GetAuthURL ...
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize Exchange the code the issuer sent back for tokens ...
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	config, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	token, err := config.Exchange(goth.ContextForClient(p.client()), params.Get("code"))
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid() {
+		return "", errors.New("Invalid token received from provider")
+	}
+	s.AccessToken = token.AccessToken
+	s.RefreshToken = token.RefreshToken
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		s.IDToken = idToken
+	}
+	s.ExpiresAt = token.Expiry
+	return token.AccessToken, nil
+}
+
+// Marshal ...
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return s.Marshal()
+}
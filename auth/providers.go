@@ -0,0 +1,136 @@
+// Package auth configures the identity providers users log in with. Providers are
+// listed in bc_auth_providers, comma separated and in the order their login buttons
+// are shown, google when it is not set:
+//
+//	google     bc_intranet_client_id, bc_intranet_client_secret
+//	microsoft  bc_microsoft_client_id, bc_microsoft_client_secret, bc_microsoft_tenant (the
+//	           directory id), the app registration must send the xms_edov optional claim
+//	gitlab     bc_gitlab_client_id, bc_gitlab_client_secret, bc_gitlab_url (gitlab.com by default)
+//	oidc       bc_oidc_client_id, bc_oidc_client_secret, bc_oidc_issuer, bc_oidc_label
+//	stub       bc_stub_email, development only, see Stub
+package auth
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/google"
+)
+
+// tenantID A Microsoft Entra directory id, such as
+// 72f988bf-86f1-41af-91ab-2d7cd011db47 ...
+var tenantID = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// Option A login button on the login page ...
+type Option struct {
+	Name  string
+	Label string
+}
+
+// StubPath Where the development stub issuer is served ...
+const StubPath = "/oidc-stub"
+
+var (
+	// options Login buttons of the enabled providers ...
+	options []Option
+	// stub Development issuer, nil unless the stub provider is enabled ...
+	stub *Stub
+)
+
+// Options Login buttons of the enabled providers ...
+func Options() []Option {
+	return options
+}
+
+// StubHandler The development stub issuer, nil unless it is enabled ...
+func StubHandler() http.Handler {
+	if stub == nil {
+		return nil
+	}
+	return stub
+}
+
+// UseProviders Register the providers listed in bc_auth_providers with goth, host
+// is the base url of the app that callbacks come back to. Providers that are not
+// fully configured are skipped with a log message ...
+func UseProviders(host string) {
+	names := os.Getenv("bc_auth_providers")
+	if strings.TrimSpace(names) == "" {
+		names = "google"
+	}
+	options = nil
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		callbackURL := host + "/auth/" + name + "/callback"
+		var provider goth.Provider
+		label := name
+		switch name {
+		case "google":
+			provider = google.New(os.Getenv("bc_intranet_client_id"), os.Getenv("bc_intranet_client_secret"), callbackURL)
+			label = "Google"
+		case "microsoft":
+			tenant := strings.ToLower(strings.TrimSpace(os.Getenv("bc_microsoft_tenant")))
+			if !tenantID.MatchString(tenant) {
+				log.Println("auth: microsoft needs bc_microsoft_tenant set to the directory id of the tenant, common, organizations and consumers sign in accounts of any tenant")
+				continue
+			}
+			microsoft := NewOIDC(name, "https://login.microsoftonline.com/"+tenant+"/v2.0",
+				os.Getenv("bc_microsoft_client_id"), os.Getenv("bc_microsoft_client_secret"), callbackURL)
+			// Entra lets users set their own email, only domain verified ones count
+			microsoft.Tenant = tenant
+			microsoft.EmailVerifiedClaim = "xms_edov"
+			provider = microsoft
+			label = "Microsoft"
+		case "gitlab":
+			base := os.Getenv("bc_gitlab_url")
+			if base == "" {
+				base = "https://gitlab.com"
+			}
+			provider = NewOIDC(name, base, os.Getenv("bc_gitlab_client_id"), os.Getenv("bc_gitlab_client_secret"), callbackURL)
+			label = "GitLab"
+		case "oidc":
+			issuer := os.Getenv("bc_oidc_issuer")
+			if issuer == "" {
+				log.Println("auth: oidc needs bc_oidc_issuer")
+				continue
+			}
+			provider = NewOIDC(name, issuer, os.Getenv("bc_oidc_client_id"), os.Getenv("bc_oidc_client_secret"), callbackURL)
+			label = os.Getenv("bc_oidc_label")
+			if label == "" {
+				label = "Single Sign-On"
+			}
+		case "stub":
+			if os.Getenv("bc_env") != "development" || os.Getenv("bc_stub_email") == "" {
+				log.Println("auth: stub is only available in development with bc_stub_email")
+				continue
+			}
+			stub = NewStub(host+StubPath, os.Getenv("bc_stub_email"))
+			provider = NewOIDC(name, stub.Issuer, "stub", "stub", callbackURL)
+			label = "Stub Login"
+		default:
+			log.Println("auth: unknown provider " + name)
+			continue
+		}
+		goth.UseProviders(provider)
+		options = append(options, Option{Name: name, Label: label})
+	}
+}
+
+// EmailVerified Whether the provider vouches for the email of the user, accounts
+// are linked across providers by email so unverified emails are refused ...
+func EmailVerified(user goth.User) bool {
+	return claimBool(user.RawData["email_verified"]) || claimBool(user.RawData["verified_email"])
+}
+
+// UserID Id of a new user, ids of providers other than google are prefixed with the
+// provider so they can not clash ...
+func UserID(user goth.User) string {
+	if user.Provider == "google" {
+		return user.UserID
+	}
+	return user.Provider + ":" + user.UserID
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Stub Minimal OpenID Connect issuer that signs in one configured user without
+// asking for a password, so logins can be tried offline in development and tests.
+// It serves the discovery document, authorize, token and userinfo endpoints under
+// the path it is mounted at ...
+type Stub struct {
+	Issuer string
+	Claims map[string]interface{}
+	mutex  sync.Mutex
+	codes  map[string]bool
+	tokens map[string]bool
+}
+
+// NewStub Stub issuer at a base url for a user with an email ...
+func NewStub(issuer string, email string) *Stub {
+	name := strings.SplitN(email, "@", 2)[0]
+	return &Stub{
+		Issuer: strings.TrimSuffix(issuer, "/"),
+		Claims: map[string]interface{}{
+			"sub":            "stub-" + name,
+			"email":          email,
+			"email_verified": true,
+			"name":           name,
+			"given_name":     name,
+		},
+		codes:  make(map[string]bool),
+		tokens: make(map[string]bool),
+	}
+}
+
+// randomString ...
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// writeJSON ...
+func writeJSON(res http.ResponseWriter, v interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(v)
+}
+
+// idToken An unsigned id token with the claims of the user, issued for a client ...
+func (s *Stub) idToken(clientID string) string {
+	claims := map[string]interface{}{"iss": s.Issuer, "aud": clientID}
+	for name, value := range s.Claims {
+		claims[name] = value
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "none", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+// ServeHTTP Paths are relative to the issuer ...
+func (s *Stub) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch strings.TrimSuffix(req.URL.Path, "/") {
+	case "/.well-known/openid-configuration":
+		writeJSON(res, map[string]string{
+			"issuer":                 s.Issuer,
+			"authorization_endpoint": s.Issuer + "/authorize",
+			"token_endpoint":         s.Issuer + "/token",
+			"userinfo_endpoint":      s.Issuer + "/userinfo",
+		})
+	case "/authorize":
+		redirect, err := url.Parse(req.URL.Query().Get("redirect_uri"))
+		if err != nil || redirect.Host == "" {
+			http.Error(res, "redirect_uri is required", http.StatusBadRequest)
+			return
+		}
+		code := randomString()
+		s.codes[code] = true
+		query := redirect.Query()
+		query.Set("code", code)
+		query.Set("state", req.URL.Query().Get("state"))
+		redirect.RawQuery = query.Encode()
+		http.Redirect(res, req, redirect.String(), http.StatusFound)
+	case "/token":
+		code := req.FormValue("code")
+		if !s.codes[code] {
+			http.Error(res, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		delete(s.codes, code)
+		token := randomString()
+		s.tokens[token] = true
+		clientID, _, ok := req.BasicAuth()
+		if !ok {
+			clientID = req.FormValue("client_id")
+		}
+		writeJSON(res, map[string]interface{}{
+			"access_token": token, "token_type": "Bearer", "expires_in": 3600,
+			"id_token": s.idToken(clientID),
+		})
+	case "/userinfo":
+		if !s.tokens[strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")] {
+			http.Error(res, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		writeJSON(res, s.Claims)
+	default:
+		http.NotFound(res, req)
+	}
+}
@@ -0,0 +1,177 @@
+package bankexport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"bcpayslip/currency"
+	"bcpayslip/models"
+	"bcpayslip/money"
+	"bcpayslip/validators"
+)
+
+type (
+	// Format Layout of a salary transfer file a bank accepts for upload ...
+	Format interface {
+		// Name Short name used in urls ...
+		Name() string
+		// Label Name shown to HR ...
+		Label() string
+		// Extension File extension including the dot ...
+		Extension() string
+		// ContentType Mime type of the file ...
+		ContentType() string
+		// Write Write the transfers of a batch ...
+		Write(w io.Writer, batch Batch) error
+	}
+	// Transfer Net pay of one employee to credit ...
+	Transfer struct {
+		EmployeeNo string
+		Name       string
+		AccountNo  string
+		IFSCCode   string
+		Paise      int64
+		Narration  string
+	}
+	// Batch Salary transfers of a month from the company account ...
+	Batch struct {
+		Month        time.Time
+		DebitAccount string
+		Transfers    []Transfer
+	}
+	// Control Count and net pay of the rupee payslips to pay, worked out apart from
+	// the payslips a batch is built from ...
+	Control struct {
+		Count int
+		Net   money.Amount
+	}
+	// RecordError Payslips that can not be paid by bank transfer ...
+	RecordError struct {
+		Problems []string
+	}
+)
+
+// formats Registered transfer file formats by name ...
+var formats = map[string]Format{}
+
+// Register Make a transfer file format available for export ...
+func Register(format Format) {
+	formats[format.Name()] = format
+}
+
+// Lookup Find a transfer file format by name ...
+func Lookup(name string) (Format, bool) {
+	format, ok := formats[name]
+	return format, ok
+}
+
+// Formats All registered transfer file formats ordered by name ...
+func Formats() []Format {
+	var list []Format
+	for _, format := range formats {
+		list = append(list, format)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+func init() {
+	Register(CSV{})
+	Register(NEFT{})
+}
+
+// Error Every failing record on its own line ...
+func (e *RecordError) Error() string {
+	return strings.Join(e.Problems, "\n")
+}
+
+// Rupees Format paise as rupees with two decimals ...
+func Rupees(paise int64) string {
+	return fmt.Sprintf("%d.%02d", paise/100, paise%100)
+}
+
+// Total Sum of the transfers of a batch in paise ...
+func (b Batch) Total() int64 {
+	var total int64
+	for _, transfer := range b.Transfers {
+		total += transfer.Paise
+	}
+	return total
+}
+
+// Narration Description of the transfer on the employee's bank statement ...
+func Narration(month time.Time) string {
+	return "SALARY " + strings.ToUpper(month.Format("Jan 2006"))
+}
+
+// Build Make the transfer batch of approved payslips for a month. Payslips with a
+// bad account number or IFSC code fail the whole batch, nothing is paid by half a
+// file. Payslips with nothing to pay are left out, and so are payslips in a foreign
+// currency, those are paid abroad by wire transfer. An employee with more than one
+// payslip fails the batch too, and so does a batch whose count or total differs
+// from the control ...
+func Build(payslips []models.Payslip, month time.Time, debitAccount string, control Control) (Batch, error) {
+	batch := Batch{Month: month, DebitAccount: debitAccount}
+	var problems []string
+	paid := make(map[string]bool)
+	count := 0
+	for _, payslip := range payslips {
+		who := payslip.EmployeeNo + " " + payslip.Name
+		if !payslip.Status.IsDownloadable() {
+			problems = append(problems, who+": payslip is "+payslip.Status.String())
+			continue
+		}
+		employee := payslip.Requestor.UserID
+		if employee == "" {
+			employee = payslip.EmployeeNo
+		}
+		if paid[employee] {
+			problems = append(problems, who+": more than one payslip for "+month.Format("Jan 2006"))
+			continue
+		}
+		paid[employee] = true
+		if currency.IsForeign(payslip.Currency) {
+			continue
+		}
+		count++
+		paise := int64(payslip.AmountReceivedBank)
+		if paise == 0 {
+			continue
+		}
+		if paise < 0 {
+			problems = append(problems, who+": net pay is negative")
+			continue
+		}
+		if !validators.IsValidAccountNo(payslip.AccountNo) {
+			problems = append(problems, who+": invalid account number "+payslip.AccountNo)
+		}
+		if !validators.IsValidIFSC(payslip.IFSCCode) {
+			problems = append(problems, who+": invalid IFSC code "+payslip.IFSCCode)
+		}
+		batch.Transfers = append(batch.Transfers, Transfer{
+			EmployeeNo: payslip.EmployeeNo,
+			Name:       payslip.Name,
+			AccountNo:  payslip.AccountNo,
+			IFSCCode:   payslip.IFSCCode,
+			Paise:      paise,
+			Narration:  Narration(month),
+		})
+	}
+	if len(problems) > 0 {
+		return batch, &RecordError{Problems: problems}
+	}
+	if len(batch.Transfers) == 0 {
+		return batch, errors.New("no approved payslips to pay for " + month.Format("Jan 2006"))
+	}
+	if count != control.Count {
+		return batch, fmt.Errorf("%d payslips to pay but %d approved for %s", count, control.Count, month.Format("Jan 2006"))
+	}
+	if total := batch.Total(); total != int64(control.Net) {
+		return batch, fmt.Errorf("transfers total %s but net pay approved is %s", Rupees(total), control.Net)
+	}
+	return batch, nil
+}
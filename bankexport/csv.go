@@ -0,0 +1,49 @@
+package bankexport
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// CSV Generic comma separated transfer file with a header row ...
+type CSV struct{}
+
+// Name ...
+func (CSV) Name() string { return "csv" }
+
+// Label ...
+func (CSV) Label() string { return "CSV" }
+
+// Extension ...
+func (CSV) Extension() string { return ".csv" }
+
+// ContentType ...
+func (CSV) ContentType() string { return "text/csv" }
+
+// Write One row per transfer ...
+func (CSV) Write(w io.Writer, batch Batch) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Employee No", "Name", "Account No", "IFSC", "Amount", "Narration"})
+	for _, transfer := range batch.Transfers {
+		writer.Write([]string{
+			csvText(transfer.EmployeeNo),
+			csvText(transfer.Name),
+			csvText(transfer.AccountNo),
+			csvText(transfer.IFSCCode),
+			Rupees(transfer.Paise),
+			csvText(transfer.Narration),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvText A text cell quoted with ' when it starts like a formula, so a name such
+// as =HYPERLINK(...) is shown as text when the file is opened in a spreadsheet ...
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,78 @@
+package bankexport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"bcpayslip/payroll"
+)
+
+// NEFT Fixed width bulk NEFT upload, every record is neftRecordLength characters
+// ended by CRLF. The header record is
+//
+//	H, debit account (18), value date DDMMYYYY (8), transfer count (6), total (17)
+//
+// followed by one detail record per transfer
+//
+//	D, IFSC (11), account (18), amount (17), name (35), narration (30), reference (15)
+//
+// Text is upper case and left aligned, amounts are rupees with two decimals padded
+// with zeros on the left ...
+type NEFT struct{}
+
+// neftRecordLength Length of every record of a bulk NEFT file ...
+const neftRecordLength = 127
+
+// Name ...
+func (NEFT) Name() string { return "neft" }
+
+// Label ...
+func (NEFT) Label() string { return "NEFT Bulk Upload" }
+
+// Extension ...
+func (NEFT) Extension() string { return ".txt" }
+
+// ContentType ...
+func (NEFT) ContentType() string { return "text/plain" }
+
+// text Upper case text fitted to a field, characters banks reject become spaces ...
+func text(value string, width int) string {
+	field := []rune(strings.ToUpper(value))
+	for i, r := range field {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != ' ' {
+			field[i] = ' '
+		}
+	}
+	if len(field) > width {
+		field = field[:width]
+	}
+	return fmt.Sprintf("%-*s", width, string(field))
+}
+
+// amount Rupees fitted to a field, padded with zeros ...
+func amount(paise int64, width int) string {
+	return fmt.Sprintf("%0*s", width, Rupees(paise))
+}
+
+// Write The header record and one detail record per transfer ...
+func (NEFT) Write(w io.Writer, batch Batch) error {
+	if batch.DebitAccount == "" {
+		return errors.New("the company debit account is not configured")
+	}
+	records := []string{
+		"H" + text(batch.DebitAccount, 18) + payroll.PayDate(batch.Month).Format("02012006") +
+			fmt.Sprintf("%06d", len(batch.Transfers)) + amount(batch.Total(), 17),
+	}
+	for _, transfer := range batch.Transfers {
+		records = append(records, "D"+text(transfer.IFSCCode, 11)+text(transfer.AccountNo, 18)+
+			amount(transfer.Paise, 17)+text(transfer.Name, 35)+text(transfer.Narration, 30)+text(transfer.EmployeeNo, 15))
+	}
+	for _, record := range records {
+		if _, err := fmt.Fprintf(w, "%-*s\r\n", neftRecordLength, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,46 +3,76 @@ package main
 import (
 	// system local third-party
 
+	"flag"
+	"log"
 	"net/http"
 	"os"
 
+	"bcpayslip/auth"
 	"bcpayslip/routers"
+	"bcpayslip/store"
+	"bcpayslip/utils"
 
 	"github.com/gorilla/sessions"
 	_ "github.com/joho/godotenv/autoload"
-	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
-	"github.com/markbates/goth/providers/google"
 	"github.com/urfave/negroni"
 )
 
 func init() {
 	// goth package cookie store initialization
-	gothic.Store = sessions.NewCookieStore([]byte(os.Getenv("bc_app_key")))
+	gothicStore := sessions.NewCookieStore([]byte(os.Getenv("bc_app_key")))
+	// the login state only has to live until the provider sends the user back
+	gothicStore.Options = utils.CookieOptions(15 * 60)
+	gothicStore.Options.SameSite = http.SameSiteLaxMode
+	gothic.Store = gothicStore
 }
 
 func main() {
+	reencrypt := flag.Bool("reencrypt", false, "encrypt sensitive fields with the first key in bc_field_keys and exit")
+	migrateOrgs := flag.Bool("migrate-orgs", false, "assign existing records to the default organisation and exit")
+	flag.Parse()
+	if *reencrypt {
+		Reencrypt()
+		return
+	}
+	if *migrateOrgs {
+		MigrateOrganisations()
+		return
+	}
 	StartMyApp()
 }
 
+// Reencrypt - Rotate the field encryption key, run after adding a new first key to
+// bc_field_keys and before removing the old one
+func Reencrypt() {
+	updated, err := store.Reencrypt()
+	for collection, count := range updated {
+		log.Printf("%s: %d documents re-encrypted", collection, count)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// MigrateOrganisations - Run once before a second organisation is added, so that
+// it can have payroll runs for the same months as the default one
+func MigrateOrganisations() {
+	updated, err := store.MigrateOrganisations()
+	for collection, count := range updated {
+		log.Printf("%s: %d documents assigned to the default organisation", collection, count)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
 // StartMyApp - Bootstrapped function
 func StartMyApp() {
 	if os.Getenv("bc_env") == "development" {
-		goth.UseProviders(
-			google.New(
-				os.Getenv("bc_intranet_client_id"),
-				os.Getenv("bc_intranet_client_secret"),
-				os.Getenv("bc_host")+":"+os.Getenv("PORT")+"/auth/google/callback",
-			),
-		)
+		auth.UseProviders(os.Getenv("bc_host") + ":" + os.Getenv("PORT"))
 	} else {
-		goth.UseProviders(
-			google.New(
-				os.Getenv("bc_intranet_client_id"),
-				os.Getenv("bc_intranet_client_secret"),
-				os.Getenv("bc_host")+"/auth/google/callback",
-			),
-		)
+		auth.UseProviders(os.Getenv("bc_host"))
 	}
 	// get pat router from routers package
 	p := routers.GetRouter()
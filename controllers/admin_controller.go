@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bcpayslip/helpers"
+	"bcpayslip/models"
+	"bcpayslip/statutory"
+	"bcpayslip/store"
+	"bcpayslip/templates"
+	"bcpayslip/urls"
+	"bcpayslip/utils"
+	"bcpayslip/validators"
+
+	"github.com/gorilla/context"
+	uuid "github.com/satori/go.uuid"
+)
+
+// RolesController list users with the roles granted to them ...
+func RolesController(res http.ResponseWriter, req *http.Request) {
+	data := make(map[string]interface{})
+	users, err := store.GetUsers()
+	if err != nil {
+		log.Println(err)
+	}
+	granted := make(map[string]map[string]bool)
+	for _, user := range users {
+		granted[user.UserID] = make(map[string]bool)
+		for _, role := range user.Roles {
+			granted[user.UserID][role] = true
+		}
+	}
+	data["users"] = users
+	data["roles"] = models.Roles
+	data["granted"] = granted
+	utils.CustomTemplateExecute(res, req, templates.RolesTemplate, data)
+}
+
+// UserRolesController replace the roles of a user, admins can not take away their
+// own admin role so there is always someone left to grant roles ...
+func UserRolesController(res http.ResponseWriter, req *http.Request) {
+	userID := req.URL.Query().Get(":userid")
+	user, err := store.GetUser(userID)
+	if err != nil {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return
+	}
+	req.ParseForm()
+	var roles []string
+	for _, role := range models.Roles {
+		for _, value := range req.PostForm["Roles"] {
+			if value == role {
+				roles = append(roles, role)
+			}
+		}
+	}
+	granted := models.User{Roles: roles}
+	if userID == context.Get(req, "userid").(string) && !granted.HasRole(models.RoleAdmin) && user.HasRole(models.RoleAdmin) {
+		utils.RedirectWithMessage(res, req, urls.RolesPath, "You can not remove your own admin role")
+		return
+	}
+	if err = store.SetUserRoles(userID, roles); err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, urls.RolesPath, "Could not save roles")
+		return
+	}
+	utils.RedirectWithMessage(res, req, urls.RolesPath, "Roles of "+user.Email+" saved")
+}
+
+// RevokeSessionsController end every login session of a user ...
+func RevokeSessionsController(res http.ResponseWriter, req *http.Request) {
+	user, err := store.GetUser(req.URL.Query().Get(":userid"))
+	if err != nil {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return
+	}
+	revoked, err := store.DeleteUserSessions(user.UserID)
+	if err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, urls.RolesPath, "Could not revoke sessions")
+		return
+	}
+	utils.RedirectWithMessage(res, req, urls.RolesPath, fmt.Sprintf("%d sessions of %s revoked", revoked, user.Email))
+}
+
+// LoginRejectionsController list the latest logins that were turned away ...
+func LoginRejectionsController(res http.ResponseWriter, req *http.Request) {
+	data := make(map[string]interface{})
+	rejections, err := store.GetLoginRejections(100)
+	if err != nil {
+		log.Println(err)
+	}
+	data["rejections"] = rejections
+	utils.CustomTemplateExecute(res, req, templates.LoginRejectionsTemplate, data)
+}
+
+// maxLogoSize Largest logo image an organisation can upload ...
+const maxLogoSize = 1 << 20
+
+// newOrgID Placeholder id in the url for an organisation that is not saved yet ...
+const newOrgID string = "new"
+
+// organisationPath Url of the settings of an organisation ...
+func organisationPath(orgID string) string {
+	return utils.AddParamsToURL(urls.OrganisationPath, []models.Kwargs{{Key: "orgid", Value: orgID}})
+}
+
+// OrganisationsController list the organisations payslips are issued by ...
+func OrganisationsController(res http.ResponseWriter, req *http.Request) {
+	data := make(map[string]interface{})
+	organisations, err := store.GetOrganisations()
+	if err != nil {
+		log.Println(err)
+	}
+	data["organisations"] = organisations
+	utils.CustomTemplateExecute(res, req, templates.OrganisationsTemplate, data)
+}
+
+// readLogo The uploaded logo of an organisation as base64, empty when no file was
+// chosen. Only PNG and JPEG images can be drawn on payslips ...
+func readLogo(req *http.Request) (string, error) {
+	file, header, err := req.FormFile("Logo")
+	if err != nil {
+		return "", nil
+	}
+	defer file.Close()
+	if header.Size > maxLogoSize {
+		return "", errors.New("The logo can be at most 1 MB")
+	}
+	logo, err := ioutil.ReadAll(io.LimitReader(file, maxLogoSize))
+	if err != nil {
+		return "", err
+	}
+	switch http.DetectContentType(logo) {
+	case "image/png", "image/jpeg":
+		return base64.StdEncoding.EncodeToString(logo), nil
+	}
+	return "", errors.New("The logo has to be a PNG or JPEG image")
+}
+
+// OrganisationController create an organisation or edit its details, branding
+// and payslip settings. Payslip PDFs that were generated already are removed when
+// an organisation changes so they are generated again under the new settings ...
+func OrganisationController(res http.ResponseWriter, req *http.Request) {
+	orgID := req.URL.Query().Get(":orgid")
+	organisation := models.Organisation{BrandColor: models.DefaultBrandColor}
+	if orgID != newOrgID {
+		var err error
+		organisation, err = store.GetOrganisation(orgID)
+		if err != nil {
+			http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+			return
+		}
+	}
+	data := make(map[string]interface{})
+	data["orgPath"] = organisationPath(orgID)
+	data["states"] = statutory.States
+	if req.Method == "POST" {
+		organisation.Name = strings.TrimSpace(req.FormValue("Name"))
+		organisation.LegalName = strings.TrimSpace(req.FormValue("LegalName"))
+		organisation.Address = strings.TrimSpace(req.FormValue("Address"))
+		organisation.State = req.FormValue("State")
+		organisation.PAN = strings.ToUpper(strings.TrimSpace(req.FormValue("PAN")))
+		organisation.TAN = strings.ToUpper(strings.TrimSpace(req.FormValue("TAN")))
+		organisation.BrandColor = strings.TrimSpace(req.FormValue("BrandColor"))
+		organisation.AccentColor = strings.TrimSpace(req.FormValue("AccentColor"))
+		organisation.DebitAccount = strings.Replace(req.FormValue("DebitAccount"), " ", "", -1)
+		organisation.PDFProtection = models.PDFProtection{
+			Enabled:      req.FormValue("Enabled") == "true",
+			PasswordRule: strings.TrimSpace(req.FormValue("PasswordRule")),
+		}
+		errors := validators.ValidateOrganisation(&organisation)
+		if organisation.PDFProtection.Enabled || organisation.PDFProtection.PasswordRule != "" {
+			if err := helpers.ValidatePasswordRule(organisation.PDFProtection.PasswordRule); err != nil {
+				errors["PasswordRule"] = err.Error()
+			}
+		}
+		if req.FormValue("RemoveLogo") == "true" {
+			organisation.Logo = ""
+		}
+		if logo, err := readLogo(req); err != nil {
+			errors["Logo"] = err.Error()
+		} else if logo != "" {
+			organisation.Logo = logo
+		}
+		if len(errors) > 0 {
+			data["organisation"] = organisation
+			data["errors"] = errors
+			data["message"] = "Please correct the highlighted fields"
+			res.WriteHeader(http.StatusUnprocessableEntity)
+			utils.CustomTemplateExecute(res, req, templates.OrganisationTemplate, data)
+			return
+		}
+		if orgID == newOrgID {
+			organisation.OrgID = uuid.Must(uuid.NewV4(), nil).String()
+		}
+		user, _ := store.GetUser(context.Get(req, "userid").(string))
+		organisation.UpdatedBy = user.Email
+		organisation.UpdatedOn = time.Now()
+		if err := store.SaveOrganisation(&organisation); err != nil {
+			log.Println(err)
+			utils.RedirectWithMessage(res, req, organisationPath(orgID), "Could not save organisation")
+			return
+		}
+		utils.RedirectWithMessage(res, req, urls.OrganisationsPath, organisation.Name+" saved")
+		return
+	}
+	data["organisation"] = organisation
+	data["errors"] = map[string]string{}
+	if organisation.PDFProtection.Enabled {
+		data["hint"] = helpers.PasswordHint(organisation.PDFProtection.PasswordRule)
+	}
+	utils.CustomTemplateExecute(res, req, templates.OrganisationTemplate, data)
+}
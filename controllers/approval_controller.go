@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bcpayslip/models"
+	"bcpayslip/store"
+	"bcpayslip/templates"
+	"bcpayslip/urls"
+	"bcpayslip/utils"
+
+	"github.com/gorilla/context"
+)
+
+// getApprover Return the logged in user if they are allowed to approve payslips ...
+func getApprover(req *http.Request) (models.User, bool) {
+	user, err := store.GetUser(context.Get(req, "userid").(string))
+	if err != nil {
+		return user, false
+	}
+	return user, utils.HasRole(user, models.RoleApprover)
+}
+
+// getPendingPayslip Fetch the submitted payslip in the url from the organisation the
+// approver works in, approvers can not act on their own payslips ...
+func getPendingPayslip(req *http.Request, approver models.User) (models.Payslip, string) {
+	payslip, err := store.GetPayslip(req.URL.Query().Get(":uuid"))
+	if err != nil || models.OrgIDOr(payslip.OrgID) != utils.CurrentOrgID(req) {
+		return payslip, "Payslip not found"
+	}
+	if payslip.Status != models.PayslipSubmitted {
+		return payslip, "Payslip is already " + strings.ToLower(payslip.Status.String())
+	}
+	if payslip.Requestor.UserID == approver.UserID {
+		return payslip, "You can not approve your own payslip"
+	}
+	return payslip, ""
+}
+
+// ApprovalsController list payslips waiting for approval ...
+func ApprovalsController(res http.ResponseWriter, req *http.Request) {
+	if _, ok := getApprover(req); !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only approvers can view approvals")
+		return
+	}
+	data := make(map[string]interface{})
+	payslips, err := store.GetPayslipsByStatus(utils.CurrentOrgID(req), models.PayslipSubmitted)
+	if err != nil {
+		log.Println(err)
+	}
+	data["payslips"] = payslips
+	utils.CustomTemplateExecute(res, req, templates.ApprovalsTemplate, data)
+}
+
+// ApprovalsPayslipController show a submitted payslip for review ...
+func ApprovalsPayslipController(res http.ResponseWriter, req *http.Request) {
+	approver, ok := getApprover(req)
+	if !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only approvers can view approvals")
+		return
+	}
+	payslip, message := getPendingPayslip(req, approver)
+	if message != "" {
+		utils.RedirectWithMessage(res, req, urls.ApprovalsPath, message)
+		return
+	}
+	data := make(map[string]interface{})
+	data["payslip"] = payslip
+	utils.CustomTemplateExecute(res, req, templates.ApprovalsPayslipTemplate, data)
+}
+
+// ApprovePayslipController approve a submitted payslip and generate its PDF ...
+func ApprovePayslipController(res http.ResponseWriter, req *http.Request) {
+	approver, ok := getApprover(req)
+	if !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only approvers can approve payslips")
+		return
+	}
+	payslip, message := getPendingPayslip(req, approver)
+	if message != "" {
+		utils.RedirectWithMessage(res, req, urls.ApprovalsPath, message)
+		return
+	}
+	if _, err := generatePayslipPDF(&payslip); err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, urls.ApprovalsPath, "Could not generate payslip PDF, "+err.Error())
+		return
+	}
+	actOnPayslip(res, req, &payslip, approver, models.PayslipApproved)
+}
+
+// RejectPayslipController reject a submitted payslip with remarks ...
+func RejectPayslipController(res http.ResponseWriter, req *http.Request) {
+	approver, ok := getApprover(req)
+	if !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only approvers can reject payslips")
+		return
+	}
+	payslip, message := getPendingPayslip(req, approver)
+	if message != "" {
+		utils.RedirectWithMessage(res, req, urls.ApprovalsPath, message)
+		return
+	}
+	payslip.Remarks = strings.TrimSpace(req.FormValue("Remarks"))
+	actOnPayslip(res, req, &payslip, approver, models.PayslipRejected)
+}
+
+// actOnPayslip Record the approver decision on a submitted payslip ...
+func actOnPayslip(res http.ResponseWriter, req *http.Request, payslip *models.Payslip, approver models.User, status models.PayslipStatus) {
+	payslip.Status = status
+	payslip.Approver = approver
+	payslip.Approver.AccessToken = ""
+	payslip.ActedOn = time.Now()
+	if err := store.UpdatePayslipStatus(payslip, models.PayslipSubmitted); err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, urls.ApprovalsPath, "Payslip was updated by someone else")
+		return
+	}
+	utils.RedirectWithMessage(res, req, urls.ApprovalsPath, "Payslip "+strings.ToLower(status.String()))
+}
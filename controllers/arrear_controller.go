@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"bcpayslip/models"
+	"bcpayslip/money"
+	"bcpayslip/payroll"
+	"bcpayslip/store"
+	"bcpayslip/templates"
+	"bcpayslip/urls"
+	"bcpayslip/utils"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// arrearsFor Back pay lines due to an employee with the payslip of a month, from
+// the arrears HR recorded to be paid that month. Only months with an approved or
+// issued payslip were paid and get arrears ...
+func arrearsFor(employee models.Employee, month time.Time, structure models.SalaryStructure) ([]models.PayComponent, error) {
+	arrears, err := store.GetArrearsFor(employee.UserID, month)
+	if err != nil {
+		return nil, err
+	}
+	var lines []models.PayComponent
+	for _, arrear := range arrears {
+		var paid []models.Payslip
+		for _, arrearMonth := range payroll.ArrearMonths(arrear.FromMonth, arrear.ToMonth) {
+			payslip, err := store.GetPayslipFor(employee.UserID, arrearMonth)
+			if err == nil && payslip.Status.IsDownloadable() {
+				paid = append(paid, payslip)
+			}
+		}
+		arrearLines, err := payroll.Arrears(structure, arrear.NewGross, paid)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, arrearLines...)
+	}
+	return lines, nil
+}
+
+// ArrearsController list arrears and record a retroactive change of the monthly
+// gross of an employee. The difference for the months already paid is added to
+// the payslip of the pay month when the payroll for that month is run or imported ...
+func ArrearsController(res http.ResponseWriter, req *http.Request) {
+	hr, ok := getHR(req)
+	if !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can manage arrears")
+		return
+	}
+	if req.Method == "POST" {
+		employee, err := store.GetEmployee(req.FormValue("UserID"))
+		if err != nil || models.OrgIDOr(employee.OrgID) != utils.CurrentOrgID(req) {
+			utils.RedirectWithMessage(res, req, urls.ArrearsPath, "Pick an employee")
+			return
+		}
+		payMonth, err := time.Parse("2006-01", req.FormValue("PayPeriod"))
+		if err != nil {
+			utils.RedirectWithMessage(res, req, urls.ArrearsPath, "Pick the month the arrears are paid in")
+			return
+		}
+		fromMonth, err := time.Parse("2006-01", req.FormValue("FromPeriod"))
+		if err != nil {
+			utils.RedirectWithMessage(res, req, urls.ArrearsPath, "Pick the month the new gross applies from")
+			return
+		}
+		toMonth := payMonth.AddDate(0, -1, 0)
+		if period := req.FormValue("ToPeriod"); period != "" {
+			if toMonth, err = time.Parse("2006-01", period); err != nil {
+				utils.RedirectWithMessage(res, req, urls.ArrearsPath, "Pick the last month the arrears cover")
+				return
+			}
+		}
+		if fromMonth.After(toMonth) || !toMonth.Before(payMonth) {
+			utils.RedirectWithMessage(res, req, urls.ArrearsPath, "Arrears cover months before the month they are paid in")
+			return
+		}
+		newGross, err := money.Parse(req.FormValue("NewGross"))
+		if err != nil || newGross <= 0 {
+			utils.RedirectWithMessage(res, req, urls.ArrearsPath, "Enter the new monthly gross")
+			return
+		}
+		if payslip, err := store.GetPayslipFor(employee.UserID, payMonth); err == nil && payslip.Status.IsDownloadable() {
+			utils.RedirectWithMessage(res, req, urls.ArrearsPath, "The payslip for "+payMonth.Format("Jan 2006")+" is already "+payslip.Status.String()+", pay the arrears in a later month")
+			return
+		}
+		arrear := models.Arrear{
+			ArrearID:   uuid.Must(uuid.NewV4(), nil).String(),
+			OrgID:      utils.CurrentOrgID(req),
+			UserID:     employee.UserID,
+			EmployeeNo: employee.EmployeeNo,
+			Name:       employee.Name,
+			FromMonth:  fromMonth,
+			ToMonth:    toMonth,
+			PayMonth:   payMonth,
+			PayPeriod:  payMonth.Format("2006-01"),
+			NewGross:   newGross,
+			Reason:     req.FormValue("Reason"),
+			CreatedBy:  hr.Email,
+			CreatedOn:  time.Now(),
+		}
+		if err = store.SaveArrear(&arrear); err != nil {
+			log.Println(err)
+			utils.RedirectWithMessage(res, req, urls.ArrearsPath, "Could not save the arrears, try again")
+			return
+		}
+		// the new gross usually applies from the pay month on as well
+		if req.FormValue("UpdateGross") == "true" && employee.MonthlyGross != newGross {
+			employee.MonthlyGross = newGross
+			employee.UpdatedBy = hr.Email
+			employee.UpdatedOn = time.Now()
+			if err = store.SaveEmployee(&employee); err != nil {
+				log.Println(err)
+				utils.RedirectWithMessage(res, req, urls.ArrearsPath, "Arrears saved but the monthly gross of "+employee.Name+" could not be updated")
+				return
+			}
+		}
+		utils.RedirectWithMessage(res, req, urls.ArrearsPath, "Arrears of "+employee.Name+" will be paid in "+payMonth.Format("Jan 2006"))
+		return
+	}
+	data := make(map[string]interface{})
+	arrears, err := store.GetArrears(utils.CurrentOrgID(req))
+	if err != nil {
+		log.Println(err)
+	}
+	employees, err := store.GetActiveEmployees(utils.CurrentOrgID(req))
+	if err != nil {
+		log.Println(err)
+	}
+	now := time.Now()
+	data["arrears"] = arrears
+	data["employees"] = employees
+	data["currentPeriod"] = now.Format("2006-01")
+	data["fromPeriod"] = now.AddDate(0, -2, 0).Format("2006-01")
+	utils.CustomTemplateExecute(res, req, templates.ArrearsTemplate, data)
+}
+
+// DeleteArrearController remove arrears recorded by mistake, arrears already paid
+// on an approved or issued payslip stay ...
+func DeleteArrearController(res http.ResponseWriter, req *http.Request) {
+	if _, ok := getHR(req); !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can manage arrears")
+		return
+	}
+	arrear, err := store.GetArrear(req.URL.Query().Get(":arrearid"))
+	if err != nil || models.OrgIDOr(arrear.OrgID) != utils.CurrentOrgID(req) {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return
+	}
+	if payslip, err := store.GetPayslipFor(arrear.UserID, arrear.PayMonth); err == nil && payslip.Status.IsDownloadable() {
+		utils.RedirectWithMessage(res, req, urls.ArrearsPath, "These arrears are already paid on an "+payslip.Status.String()+" payslip")
+		return
+	}
+	if err = store.DeleteArrear(arrear.ArrearID); err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, urls.ArrearsPath, "Could not remove the arrears, try again")
+		return
+	}
+	utils.RedirectWithMessage(res, req, urls.ArrearsPath, "Arrears of "+arrear.Name+" removed")
+}
@@ -1,19 +1,21 @@
 package controllers
 
 import (
+	"html/template"
+	"log"
 	"net/http"
-	"strings"
-	"text/template"
+	"time"
 
+	"bcpayslip/auth"
+	"bcpayslip/models"
 	"bcpayslip/store"
 	"bcpayslip/templates"
 	"bcpayslip/urls"
 	"bcpayslip/utils"
 
 	"github.com/gorilla/context"
-	"github.com/gorilla/sessions"
-	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
+	uuid "github.com/satori/go.uuid"
 )
 
 // LoginController login page controller ...
@@ -24,15 +26,15 @@ func LoginController(res http.ResponseWriter, req *http.Request) {
 		http.Redirect(res, req, urls.HomePath, http.StatusSeeOther)
 	} else {
 		t, _ := template.ParseFiles(templates.LoginTemplate)
-		t.Execute(res, nil)
+		t.Execute(res, map[string]interface{}{"providers": auth.Options()})
 	}
 }
 
 // LogoutController delete the cookie and redirect ...
 func LogoutController(res http.ResponseWriter, req *http.Request) {
-	session, _ := utils.GetValidSession(req)
-	session.Options = &sessions.Options{Path: urls.RootPath, MaxAge: -1}
-	session.Save(req, res)
+	if err := utils.EndSession(res, req); err != nil {
+		log.Println(err)
+	}
 	http.Redirect(res, req, urls.RootPath, http.StatusTemporaryRedirect)
 }
 
@@ -41,27 +43,83 @@ func AuthController(res http.ResponseWriter, req *http.Request) {
 	gothic.BeginAuthHandler(res, req)
 }
 
+// rejectLogin Record a refused login, drop the session and send the user back to the login page ...
+func rejectLogin(res http.ResponseWriter, req *http.Request, email string, reason string, message string) {
+	rejection := models.LoginRejection{
+		RejectionID: uuid.Must(uuid.NewV4(), nil).String(),
+		Email:       email,
+		Provider:    req.URL.Query().Get(":provider"),
+		Reason:      reason,
+		RemoteAddr:  req.RemoteAddr,
+		AttemptedOn: time.Now(),
+	}
+	if err := store.SaveLoginRejection(&rejection); err != nil {
+		log.Println(err)
+	}
+	if err := utils.EndSession(res, req); err != nil {
+		log.Println(err)
+	}
+	utils.RedirectWithMessage(res, req, urls.RootPath, message)
+}
+
 // AuthCallbackController goth callback controller to complete user auth and create user ...
 func AuthCallbackController(res http.ResponseWriter, req *http.Request) {
-	var gothUser goth.User
 	gothUser, err := gothic.CompleteUserAuth(res, req)
 	if err != nil {
-		gothic.BeginAuthHandler(res, req)
+		rejectLogin(res, req, "", "authentication failed: "+err.Error(), "Login failed, please try again")
+		return
 	}
-	emailDomain := strings.Join(strings.Split(gothUser.Email, "@")[1:], "a")
-	if emailDomain != "beautifulcode.in" {
-		session, _ := utils.GetValidSession(req)
-		session.Options = &sessions.Options{Path: urls.RootPath, MaxAge: -1}
-		session.Save(req, res)
-		queryParam := "?m=Invalid account, use BC account"
-		http.Redirect(res, req, urls.RootPath+queryParam, http.StatusSeeOther)
+	if !auth.EmailVerified(gothUser) {
+		rejectLogin(res, req, gothUser.Email, "email is not verified", "Verify your email with "+gothUser.Provider+" and try again")
+		return
 	}
-	session, _ := utils.GetValidSession(req)
-	session.Values["userid"] = gothUser.UserID
-	session.Save(req, res)
-	store.SaveUser(
-		gothUser.UserID, gothUser.FirstName, gothUser.LastName,
+	if allowed, reason := utils.LoginAllowed(gothUser.Email); !allowed {
+		rejectLogin(res, req, gothUser.Email, reason, "This account is not allowed to use the app")
+		return
+	}
+	// the same person logging in through another provider keeps their account
+	userID := auth.UserID(gothUser)
+	if user, err := store.GetUserByEmail(gothUser.Email); err == nil {
+		userID = user.UserID
+	}
+	if err = store.SaveUser(
+		userID, gothUser.FirstName, gothUser.LastName,
 		gothUser.Email, gothUser.AccessToken, gothUser.AvatarURL,
-	)
+	); err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, urls.RootPath, "Login failed, please try again")
+		return
+	}
+	if err = utils.StartSession(res, req, userID); err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, urls.RootPath, "Login failed, please try again")
+		return
+	}
 	http.Redirect(res, req, urls.HomePath, http.StatusSeeOther)
 }
+
+// SessionsController list the login sessions of the user ...
+func SessionsController(res http.ResponseWriter, req *http.Request) {
+	data := make(map[string]interface{})
+	userSessions, err := store.GetUserSessions(context.Get(req, "userid").(string))
+	if err != nil {
+		log.Println(err)
+	}
+	current, _ := utils.GetValidSession(req)
+	data["sessions"] = userSessions
+	data["currentSession"] = current.ID
+	utils.CustomTemplateExecute(res, req, templates.SessionsTemplate, data)
+}
+
+// SignOutEverywhereController end every login session of the user, including this one ...
+func SignOutEverywhereController(res http.ResponseWriter, req *http.Request) {
+	if _, err := store.DeleteUserSessions(context.Get(req, "userid").(string)); err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, urls.SessionsPath, "Could not sign out everywhere")
+		return
+	}
+	if err := utils.EndSession(res, req); err != nil {
+		log.Println(err)
+	}
+	utils.RedirectWithMessage(res, req, urls.RootPath, "Signed out everywhere")
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"bcpayslip/helpers"
+	"bcpayslip/models"
+	"bcpayslip/store"
+	"bcpayslip/templates"
+	"bcpayslip/urls"
+	"bcpayslip/utils"
+
+	"github.com/gorilla/context"
+	uuid "github.com/satori/go.uuid"
+)
+
+// shareLinkValidity how long a shared payslip link can be used ...
+const shareLinkValidity = 7 * 24 * time.Hour
+
+// PayslipDownloadController stream the PDF of an approved payslip to its owner or
+// an approver of its organisation, the first download by the owner marks the
+// payslip as issued ...
+func PayslipDownloadController(res http.ResponseWriter, req *http.Request) {
+	payslip, err := store.GetPayslip(req.URL.Query().Get(":uuid"))
+	if err != nil {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return
+	}
+	user, _ := store.GetUser(context.Get(req, "userid").(string))
+	via := "owner"
+	if payslip.Requestor.UserID != user.UserID {
+		if !utils.HasRole(user, models.RoleApprover) || models.OrgIDOr(payslip.OrgID) != utils.CurrentOrgID(req) {
+			http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+			return
+		}
+		via = "approver"
+	}
+	if !payslip.Status.IsDownloadable() {
+		utils.RedirectWithMessage(res, req, urls.PayslipsPath, "Payslip is not approved yet")
+		return
+	}
+	if via == "owner" && payslip.Status == models.PayslipApproved {
+		payslip.Status = models.PayslipIssued
+		payslip.IssuedOn = time.Now()
+		if err := store.UpdatePayslipStatus(&payslip, models.PayslipApproved); err != nil {
+			log.Println(err)
+		}
+	}
+	servePayslipPDF(res, req, &payslip, user, via)
+}
+
+// PayslipShareController show a signed link the owner can hand to a bank ...
+func PayslipShareController(res http.ResponseWriter, req *http.Request) {
+	payslip, ok := getOwnPayslip(req)
+	if !ok {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return
+	}
+	if !payslip.Status.IsDownloadable() {
+		utils.RedirectWithMessage(res, req, urls.PayslipsPath, "Payslip is not approved yet")
+		return
+	}
+	expires := time.Now().Add(shareLinkValidity)
+	link := utils.AddParamsToURL(urls.SharedPayslipPath, []models.Kwargs{{Key: "uuid", Value: payslip.UUID}})
+	link += "?expires=" + strconv.FormatInt(expires.Unix(), 10) +
+		"&sig=" + helpers.SignPayslipLink(payslip.UUID, expires.Unix())
+	data := make(map[string]interface{})
+	data["payslip"] = payslip
+	data["link"] = os.Getenv("bc_host") + link
+	data["expires"] = expires
+	data["pdfPassword"] = pdfPasswordHint(payslip.OrgID)
+	utils.CustomTemplateExecute(res, req, templates.PayslipShareTemplate, data)
+}
+
+// SharedPayslipController stream a payslip PDF to anyone holding a valid signed link ...
+func SharedPayslipController(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || !helpers.VerifyPayslipLink(query.Get(":uuid"), expires, query.Get("sig"), time.Now()) {
+		http.Error(res, "This payslip link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+	payslip, err := store.GetPayslip(query.Get(":uuid"))
+	if err != nil || !payslip.Status.IsDownloadable() {
+		http.Error(res, "This payslip link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+	servePayslipPDF(res, req, &payslip, models.User{}, "link")
+}
+
+// generatePayslipPDF Generate the PDF of a payslip with the branding and protection
+// settings of its organisation, sign it and keep the signed bytes and signature.
+// The PDF is drawn in memory and reaches media/ only once it is signed and kept.
+// A payslip that is signed already gets the PDF as it was signed and is never
+// signed again, PDFs given to banks keep matching their signature ...
+func generatePayslipPDF(payslip *models.Payslip) ([]byte, error) {
+	if stored, pdf, err := store.GetPayslipPDF(payslip.UUID); err == nil {
+		return pdf, restoreSignature(payslip, stored)
+	}
+	if payslip.Signature != "" {
+		return nil, errors.New("the signed PDF of this payslip is missing")
+	}
+	organisation, err := store.GetOrganisation(models.OrgIDOr(payslip.OrgID))
+	if err != nil {
+		return nil, err
+	}
+	if payslip.VerificationCode == "" {
+		if payslip.VerificationCode, err = helpers.NewVerificationCode(); err != nil {
+			return nil, err
+		}
+	}
+	pdf, err := helpers.DrawPayslipPDF(payslip, organisation)
+	if err != nil {
+		return nil, err
+	}
+	if payslip.SignatureKey, payslip.Signature, err = helpers.SignPayslipPDF(pdf); err != nil {
+		return nil, err
+	}
+	payslip.SignedOn = time.Now()
+	if err = store.SavePayslipPDF(payslip, pdf); err == store.ErrPayslipSigned {
+		// another download signed it first, hand out what it signed
+		stored, pdf, err := store.GetPayslipPDF(payslip.UUID)
+		if err != nil {
+			return nil, err
+		}
+		return pdf, restoreSignature(payslip, stored)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = store.SetPayslipSignature(payslip); err != nil {
+		return nil, err
+	}
+	if err = helpers.WritePayslipPDF(payslip.UUID, pdf); err != nil {
+		log.Println(err)
+	}
+	return pdf, nil
+}
+
+// restoreSignature Put the signature of the kept PDF back on its payslip when the
+// payslip lost it, a download that failed after signing leaves it unsaved ...
+func restoreSignature(payslip *models.Payslip, stored models.PayslipPDF) error {
+	if stored.Signature == "" || stored.Signature == payslip.Signature {
+		return nil
+	}
+	payslip.VerificationCode = stored.VerificationCode
+	payslip.SignatureKey = stored.SignatureKey
+	payslip.Signature = stored.Signature
+	payslip.SignedOn = stored.SignedOn
+	return store.SetPayslipSignature(payslip)
+}
+
+// pdfPasswordHint How to open the payslip PDFs of an organisation, empty when
+// they are not protected ...
+func pdfPasswordHint(orgID string) string {
+	organisation, err := store.GetOrganisation(models.OrgIDOr(orgID))
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	if !organisation.PDFProtection.Enabled {
+		return ""
+	}
+	return helpers.PasswordHint(organisation.PDFProtection.PasswordRule)
+}
+
+// servePayslipPDF Write the signed payslip PDF as an attachment and log the access,
+// it is served from the signed bytes kept for it and not from media/ ...
+func servePayslipPDF(res http.ResponseWriter, req *http.Request, payslip *models.Payslip, user models.User, via string) {
+	pdf, err := generatePayslipPDF(payslip)
+	if err != nil {
+		log.Println(err)
+		http.Error(res, "Could not generate payslip PDF, "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	access := models.PayslipAccess{
+		AccessID:   uuid.Must(uuid.NewV4(), nil).String(),
+		UUID:       payslip.UUID,
+		UserID:     user.UserID,
+		Email:      user.Email,
+		Via:        via,
+		RemoteAddr: req.RemoteAddr,
+		AccessedOn: time.Now(),
+	}
+	if err = store.SavePayslipAccess(access); err != nil {
+		log.Println(err)
+	}
+	log.Printf("payslip %s downloaded by %q via %s from %s", payslip.UUID, user.Email, via, req.RemoteAddr)
+	filename := "payslip-" + payslip.Month.Format("Jan-2006") + ".pdf"
+	res.Header().Set("Content-Type", "application/pdf")
+	res.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	res.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(res, req, filename, payslip.ActedOn, bytes.NewReader(pdf))
+}
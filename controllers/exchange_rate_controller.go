@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bcpayslip/currency"
+	"bcpayslip/models"
+	"bcpayslip/store"
+	"bcpayslip/templates"
+	"bcpayslip/urls"
+	"bcpayslip/utils"
+)
+
+// ExchangeRatesController list exchange rates and set the rate of a currency from a
+// month on. Payslips keep the rate they were calculated with, a new rate only
+// applies to payslips calculated after it is set ...
+func ExchangeRatesController(res http.ResponseWriter, req *http.Request) {
+	hr, ok := getHR(req)
+	if !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can manage exchange rates")
+		return
+	}
+	if req.Method == "POST" {
+		code := req.FormValue("Currency")
+		if _, ok := currency.Lookup(code); !ok || !currency.IsForeign(code) {
+			utils.RedirectWithMessage(res, req, urls.ExchangeRatesPath, "Pick a foreign currency")
+			return
+		}
+		month, err := time.Parse("2006-01", req.FormValue("Period"))
+		if err != nil {
+			utils.RedirectWithMessage(res, req, urls.ExchangeRatesPath, "Pick the month the rate applies from")
+			return
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(req.FormValue("Rate")), 64)
+		if err != nil || rate <= 0 {
+			utils.RedirectWithMessage(res, req, urls.ExchangeRatesPath, "Enter the rupees one "+code+" is worth")
+			return
+		}
+		exchangeRate := models.ExchangeRate{
+			Currency:  code,
+			Period:    month.Format("2006-01"),
+			Month:     month,
+			Rate:      rate,
+			UpdatedBy: hr.Email,
+			UpdatedOn: time.Now(),
+		}
+		if err = store.SaveExchangeRate(&exchangeRate); err != nil {
+			log.Println(err)
+			utils.RedirectWithMessage(res, req, urls.ExchangeRatesPath, "Could not save the exchange rate, try again")
+			return
+		}
+		utils.RedirectWithMessage(res, req, urls.ExchangeRatesPath, "Exchange rate of "+code+" saved from "+month.Format("Jan 2006"))
+		return
+	}
+	data := make(map[string]interface{})
+	rates, err := store.GetExchangeRates()
+	if err != nil {
+		log.Println(err)
+	}
+	var foreign []currency.Currency
+	for _, c := range currency.All() {
+		if currency.IsForeign(c.Code) {
+			foreign = append(foreign, c)
+		}
+	}
+	data["rates"] = rates
+	data["currencies"] = foreign
+	data["base"] = currency.Base
+	data["currentPeriod"] = time.Now().Format("2006-01")
+	utils.CustomTemplateExecute(res, req, templates.ExchangeRatesTemplate, data)
+}
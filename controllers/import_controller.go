@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"bcpayslip/importer"
+	"bcpayslip/models"
+	"bcpayslip/money"
+	"bcpayslip/payroll"
+	"bcpayslip/store"
+	"bcpayslip/tax"
+	"bcpayslip/templates"
+	"bcpayslip/urls"
+	"bcpayslip/utils"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// maxImportSize Largest spreadsheet accepted for a payroll data import ...
+const maxImportSize = 10 << 20
+
+// importPath Url of a payroll data import ...
+func importPath(importID string) string {
+	return utils.AddParamsToURL(urls.ImportPath, []models.Kwargs{{Key: "importid", Value: importID}})
+}
+
+// getPayrollImport Fetch the payroll data import in the url for HR, imports of
+// other organisations than the one HR works in are not found ...
+func getPayrollImport(res http.ResponseWriter, req *http.Request) (models.PayrollImport, models.User, bool) {
+	var payrollImport models.PayrollImport
+	hr, ok := getHR(req)
+	if !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can import payroll data")
+		return payrollImport, hr, false
+	}
+	payrollImport, err := store.GetPayrollImport(req.URL.Query().Get(":importid"))
+	if err != nil || models.OrgIDOr(payrollImport.OrgID) != utils.CurrentOrgID(req) {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return payrollImport, hr, false
+	}
+	return payrollImport, hr, true
+}
+
+// checkImport Match the rows of an import to employees of its organisation and
+// calculate their payslips,
+// filling what committing each row would do. The payslips are returned in the
+// order of the rows, rows with errors get an empty payslip ...
+func checkImport(payrollImport *models.PayrollImport) []models.Payslip {
+	payslips := make([]models.Payslip, len(payrollImport.Rows))
+	employees, err := store.GetEmployees(models.OrgIDOr(payrollImport.OrgID))
+	if err != nil {
+		log.Println(err)
+	}
+	byNumber := make(map[string]models.Employee)
+	for _, employee := range employees {
+		if employee.EmployeeNo != "" {
+			byNumber[employee.EmployeeNo] = employee
+		}
+	}
+	month := payrollImport.Month
+	for i := range payrollImport.Rows {
+		row := &payrollImport.Rows[i]
+		row.Warnings = nil
+		row.Action = ""
+		if len(row.Errors) > 0 {
+			continue
+		}
+		employee, ok := byNumber[row.EmployeeNo]
+		if !ok {
+			row.Errors = append(row.Errors, "Employee No "+row.EmployeeNo+" is not in the employee records")
+			continue
+		}
+		row.UserID = employee.UserID
+		row.Name = employee.Name
+		if !employee.Active {
+			row.Warnings = append(row.Warnings, "employee is not active")
+		}
+		structure, err := store.GetSalaryStructureFor(employee.UserID, employee.OrgID, employee.Grade)
+		if err != nil {
+			structure = payroll.DefaultStructure()
+		}
+		// arrears are worked out again every time, the row keeps what was imported
+		input := row.Input
+		input.Arrears, err = arrearsFor(employee, month, structure)
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+			continue
+		}
+		payslip, err := payroll.PayslipWith(employee, month, structure, input)
+		if err == nil {
+			err = setExchangeRate(&payslip)
+		}
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+			continue
+		}
+		row.Net = payslip.AmountReceivedBank
+		if difference := row.Input.BankCredit - row.Net; row.Input.BankCredit > 0 && (difference >= money.Rupees(1) || difference <= -money.Rupees(1)) {
+			row.Warnings = append(row.Warnings, fmt.Sprintf("Bank Credit %s does not match the calculated net pay %s", row.Input.BankCredit, row.Net))
+		}
+		row.Action = models.ImportCreate
+		if previous, err := store.GetPayslipFor(employee.UserID, payslip.Month); err == nil {
+			if previous.Status.IsDownloadable() {
+				row.Errors = append(row.Errors, "the payslip for "+month.Format("Jan 2006")+" is already "+previous.Status.String())
+				row.Action = ""
+				continue
+			}
+			row.Action = models.ImportUpdate
+			row.PreviousUUID = previous.UUID
+			row.PreviousNet = previous.AmountReceivedBank
+		}
+		payslips[i] = payslip
+	}
+	return payslips
+}
+
+// ImportsController list payroll data imports and upload a new spreadsheet, the
+// upload is checked in a dry run and nothing is created until it is committed ...
+func ImportsController(res http.ResponseWriter, req *http.Request) {
+	hr, ok := getHR(req)
+	if !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can import payroll data")
+		return
+	}
+	if req.Method == "POST" {
+		// the form is read by the csrf middleware already, within its own size limit
+		if err := req.ParseMultipartForm(maxImportSize); err != nil {
+			utils.RedirectWithMessage(res, req, urls.ImportsPath, "The file is too large to import")
+			return
+		}
+		month, err := time.Parse("2006-01", req.FormValue("Period"))
+		if err != nil {
+			utils.RedirectWithMessage(res, req, urls.ImportsPath, "Pick the month of the payroll data")
+			return
+		}
+		if _, err = tax.YearFor(month); err != nil {
+			utils.RedirectWithMessage(res, req, urls.ImportsPath, err.Error())
+			return
+		}
+		file, header, err := req.FormFile("File")
+		if err != nil {
+			utils.RedirectWithMessage(res, req, urls.ImportsPath, "Choose a spreadsheet to import")
+			return
+		}
+		defer file.Close()
+		if header.Size > maxImportSize {
+			utils.RedirectWithMessage(res, req, urls.ImportsPath, "The file is too large to import")
+			return
+		}
+		records, err := importer.Read(file, header.Filename)
+		if err != nil {
+			utils.RedirectWithMessage(res, req, urls.ImportsPath, "Could not read the file, "+err.Error())
+			return
+		}
+		rows, err := importer.ParseRows(records, month)
+		if err != nil {
+			utils.RedirectWithMessage(res, req, urls.ImportsPath, err.Error())
+			return
+		}
+		payrollImport := models.PayrollImport{
+			ImportID:  uuid.Must(uuid.NewV4(), nil).String(),
+			OrgID:     utils.CurrentOrgID(req),
+			Period:    month.Format("2006-01"),
+			Month:     month,
+			FileName:  header.Filename,
+			CreatedBy: hr.Email,
+			CreatedOn: time.Now(),
+			Rows:      rows,
+		}
+		checkImport(&payrollImport)
+		if err = store.SavePayrollImport(&payrollImport); err != nil {
+			log.Println(err)
+			utils.RedirectWithMessage(res, req, urls.ImportsPath, "Could not save the import")
+			return
+		}
+		http.Redirect(res, req, importPath(payrollImport.ImportID), http.StatusSeeOther)
+		return
+	}
+	data := make(map[string]interface{})
+	imports, err := store.GetPayrollImports(utils.CurrentOrgID(req), 20)
+	if err != nil {
+		log.Println(err)
+	}
+	data["imports"] = imports
+	data["currentPeriod"] = time.Now().Format("2006-01")
+	utils.CustomTemplateExecute(res, req, templates.ImportsTemplate, data)
+}
+
+// ImportController show the dry run of a payroll data import ...
+func ImportController(res http.ResponseWriter, req *http.Request) {
+	payrollImport, _, ok := getPayrollImport(res, req)
+	if !ok {
+		return
+	}
+	data := make(map[string]interface{})
+	counts := make(map[string]int)
+	for _, row := range payrollImport.Rows {
+		switch {
+		case len(row.Errors) > 0:
+			counts["errors"]++
+		case row.Action != "":
+			counts[row.Action]++
+		}
+	}
+	data["import"] = payrollImport
+	data["counts"] = counts
+	utils.CustomTemplateExecute(res, req, templates.ImportTemplate, data)
+}
+
+// ImportCommitController create the payslips of a payroll data import, the rows are
+// checked again since employee records may have changed after the dry run ...
+func ImportCommitController(res http.ResponseWriter, req *http.Request) {
+	payrollImport, hr, ok := getPayrollImport(res, req)
+	if !ok {
+		return
+	}
+	path := importPath(payrollImport.ImportID)
+	if payrollImport.Committed() {
+		utils.RedirectWithMessage(res, req, path, "This import was already committed")
+		return
+	}
+	if payrollImport.HasErrors() {
+		utils.RedirectWithMessage(res, req, path, "Fix the rows with errors and upload the file again")
+		return
+	}
+	payslips := checkImport(&payrollImport)
+	if payrollImport.HasErrors() {
+		if err := store.SavePayrollImport(&payrollImport); err != nil {
+			log.Println(err)
+		}
+		utils.RedirectWithMessage(res, req, path, "Some rows no longer pass the checks, nothing was imported")
+		return
+	}
+	// claimed before any payslip is written so a second click saves nothing
+	payrollImport.CommittedBy = hr.Email
+	payrollImport.CommittedOn = time.Now()
+	if err := store.ClaimPayrollImport(&payrollImport); err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, path, "This import was already committed")
+		return
+	}
+	var failed int
+	for i := range payrollImport.Rows {
+		row := &payrollImport.Rows[i]
+		payslip := payslips[i]
+		user, err := store.GetUser(row.UserID)
+		if err != nil {
+			row.Errors = append(row.Errors, "no login for this employee")
+			failed++
+			continue
+		}
+		user.AccessToken = ""
+		payslip.Requestor = user
+		payslip.RequestedOn = time.Now()
+		payslip.Status = models.PayslipSubmitted
+		payslip.ImportID = payrollImport.ImportID
+		payslip.UUID = row.PreviousUUID
+		if payslip.UUID == "" {
+			payslip.UUID = uuid.Must(uuid.NewV4(), nil).String()
+		}
+		if err = store.SavePayslip(&payslip); err != nil {
+			log.Println(err)
+			row.Errors = append(row.Errors, "could not save the payslip")
+			failed++
+		}
+	}
+	if err := store.SavePayrollImport(&payrollImport); err != nil {
+		log.Println(err)
+	}
+	message := fmt.Sprintf("%d payslips sent for approval", len(payrollImport.Rows)-failed)
+	if failed > 0 {
+		message += fmt.Sprintf(", %d failed", failed)
+	}
+	utils.RedirectWithMessage(res, req, path, message)
+}
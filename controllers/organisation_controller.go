@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"bcpayslip/models"
+	"bcpayslip/store"
+	"bcpayslip/urls"
+	"bcpayslip/utils"
+
+	"github.com/gorilla/context"
+)
+
+// SwitchOrganisationController pick the organisation HR and approvers work in,
+// the choice is kept in the login session and they return to the page they were on ...
+func SwitchOrganisationController(res http.ResponseWriter, req *http.Request) {
+	user, err := store.GetUser(context.Get(req, "userid").(string))
+	if err != nil || !(utils.HasRole(user, models.RoleHR) || utils.HasRole(user, models.RoleApprover)) {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "You do not have access to that page")
+		return
+	}
+	organisation, err := store.GetOrganisation(req.FormValue("OrgID"))
+	if err != nil {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "No such organisation")
+		return
+	}
+	session, _ := utils.GetValidSession(req)
+	session.Values["orgid"] = organisation.OrgID
+	if err = session.Save(req, res); err != nil {
+		log.Println(err)
+	}
+	back := urls.HomePath
+	// only pages of this app, never another host from the referer
+	if referer, err := url.Parse(req.Referer()); err == nil && strings.HasPrefix(referer.Path, urls.HomePath) {
+		back = referer.Path
+	}
+	utils.RedirectWithMessage(res, req, back, "Working in "+organisation.Name)
+}
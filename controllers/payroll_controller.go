@@ -0,0 +1,345 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"bcpayslip/bankexport"
+	"bcpayslip/currency"
+	"bcpayslip/models"
+	"bcpayslip/money"
+	"bcpayslip/payroll"
+	"bcpayslip/store"
+	"bcpayslip/tax"
+	"bcpayslip/templates"
+	"bcpayslip/urls"
+	"bcpayslip/utils"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// errNoPayslip A payroll result whose payslip is missing from the run ...
+var errNoPayslip = errors.New("no payslip in this run, preview the run again")
+
+// payrollWorkers How many employees are processed at once in a payroll run, every
+// worker copies the shared mongo session so this bounds the sockets a run takes
+// from the pool ...
+func payrollWorkers() int {
+	workers, err := strconv.Atoi(os.Getenv("bc_payroll_workers"))
+	if err != nil || workers < 1 {
+		return 8
+	}
+	return workers
+}
+
+// payrollRunPath Url of a payroll run ...
+func payrollRunPath(runID string) string {
+	return utils.AddParamsToURL(urls.PayrollRunPath, []models.Kwargs{{Key: "runid", Value: runID}})
+}
+
+// setExchangeRate Fix the rate a foreign currency payslip is valued in rupees at,
+// the rate in force for its month ...
+func setExchangeRate(payslip *models.Payslip) error {
+	payslip.ExchangeRate = 0
+	if !currency.IsForeign(payslip.Currency) {
+		return nil
+	}
+	rate, err := store.GetExchangeRate(payslip.Currency, payslip.Month)
+	if err != nil {
+		return fmt.Errorf("no exchange rate for %s up to %s", payslip.Currency, payslip.Month.Format("Jan 2006"))
+	}
+	payslip.ExchangeRate = rate.Rate
+	return nil
+}
+
+// getPayrollRun Fetch the payroll run in the url for HR, runs of other
+// organisations than the one HR works in are not found ...
+func getPayrollRun(res http.ResponseWriter, req *http.Request) (models.PayrollRun, models.User, bool) {
+	var run models.PayrollRun
+	hr, ok := getHR(req)
+	if !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can manage payroll runs")
+		return run, hr, false
+	}
+	run, err := store.GetPayrollRun(req.URL.Query().Get(":runid"))
+	if err != nil || models.OrgIDOr(run.OrgID) != utils.CurrentOrgID(req) {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return run, hr, false
+	}
+	return run, hr, true
+}
+
+// PayrollRunsController list payroll runs and start a new one for a month ...
+func PayrollRunsController(res http.ResponseWriter, req *http.Request) {
+	hr, ok := getHR(req)
+	if !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can manage payroll runs")
+		return
+	}
+	if req.Method == "POST" {
+		month, err := time.Parse("2006-01", req.FormValue("Period"))
+		if err != nil {
+			utils.RedirectWithMessage(res, req, urls.PayrollPath, "Pick the month of the payroll run")
+			return
+		}
+		run := models.PayrollRun{
+			RunID:     uuid.Must(uuid.NewV4(), nil).String(),
+			OrgID:     utils.CurrentOrgID(req),
+			Period:    month.Format("2006-01"),
+			Month:     month,
+			Status:    models.RunDraft,
+			CreatedBy: hr.Email,
+			CreatedOn: time.Now(),
+		}
+		if err = store.SavePayrollRun(&run); err != nil {
+			log.Println(err)
+			utils.RedirectWithMessage(res, req, urls.PayrollPath, "A payroll run already exists for "+month.Format("Jan 2006"))
+			return
+		}
+		http.Redirect(res, req, payrollRunPath(run.RunID), http.StatusSeeOther)
+		return
+	}
+	data := make(map[string]interface{})
+	runs, err := store.GetPayrollRuns(utils.CurrentOrgID(req))
+	if err != nil {
+		log.Println(err)
+	}
+	data["runs"] = runs
+	data["currentPeriod"] = time.Now().Format("2006-01")
+	utils.CustomTemplateExecute(res, req, templates.PayrollRunsTemplate, data)
+}
+
+// PayrollRunController show a payroll run with the outcome for every employee ...
+func PayrollRunController(res http.ResponseWriter, req *http.Request) {
+	run, _, ok := getPayrollRun(res, req)
+	if !ok {
+		return
+	}
+	data := make(map[string]interface{})
+	var gross, net money.Amount
+	var failed, foreign int
+	for _, result := range run.Results {
+		if result.Error != "" {
+			failed++
+			continue
+		}
+		// totals are in rupees, foreign currency pay is paid separately by wire
+		if currency.IsForeign(result.Currency) {
+			foreign++
+			continue
+		}
+		gross += result.Gross
+		net += result.Net
+	}
+	data["run"] = run
+	data["totalGross"] = gross
+	data["totalNet"] = net
+	data["failed"] = failed
+	data["foreign"] = foreign
+	data["base"] = currency.Base
+	data["bankFormats"] = bankexport.Formats()
+	utils.CustomTemplateExecute(res, req, templates.PayrollRunTemplate, data)
+}
+
+// PayrollPreviewController calculate draft payslips for every active employee, a run
+// can be previewed again until it is locked. Employees who already have a payslip
+// for the month get an error instead of a second payslip ...
+func PayrollPreviewController(res http.ResponseWriter, req *http.Request) {
+	run, _, ok := getPayrollRun(res, req)
+	if !ok {
+		return
+	}
+	if run.Status != models.RunDraft && run.Status != models.RunPreviewed {
+		utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), "A locked payroll run can not be previewed again")
+		return
+	}
+	if _, err := tax.YearFor(run.Month); err != nil {
+		utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), err.Error())
+		return
+	}
+	employees, err := store.GetActiveEmployees(models.OrgIDOr(run.OrgID))
+	if err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), "Could not load employees")
+		return
+	}
+	if err = store.DeleteDraftPayslipsOfRun(run.RunID); err != nil {
+		log.Println(err)
+	}
+	run.Results = payroll.RunEach(employees, payrollWorkers(), func(employee models.Employee) models.PayrollResult {
+		// the drafts of this run are gone, any payslip left for the month came
+		// from a request, an import or another run and is not paid twice
+		if previous, err := store.GetPayslipFor(employee.UserID, run.Month); err == nil && previous.Status != models.PayslipRejected {
+			return payroll.ResultOf(employee, models.Payslip{}, errors.New("the payslip for "+run.Month.Format("Jan 2006")+" is already "+previous.Status.String()))
+		}
+		structure, err := store.GetSalaryStructureFor(employee.UserID, employee.OrgID, employee.Grade)
+		if err != nil {
+			structure = payroll.DefaultStructure()
+		}
+		arrears, err := arrearsFor(employee, run.Month, structure)
+		if err != nil {
+			return payroll.ResultOf(employee, models.Payslip{}, err)
+		}
+		input := models.MonthlyInput{Gross: employee.MonthlyGross, Arrears: arrears}
+		payslip, err := payroll.PayslipWith(employee, run.Month, structure, input)
+		if err == nil {
+			err = setExchangeRate(&payslip)
+		}
+		if err != nil {
+			return payroll.ResultOf(employee, payslip, err)
+		}
+		user, err := store.GetUser(employee.UserID)
+		if err != nil {
+			return payroll.ResultOf(employee, payslip, err)
+		}
+		user.AccessToken = ""
+		payslip.Requestor = user
+		payslip.RequestedOn = time.Now()
+		payslip.Status = models.PayslipDraft
+		payslip.RunID = run.RunID
+		payslip.UUID = uuid.Must(uuid.NewV4(), nil).String()
+		err = store.SavePayslip(&payslip)
+		return payroll.ResultOf(employee, payslip, err)
+	})
+	run.Status = models.RunPreviewed
+	run.PreviewedOn = time.Now()
+	if err = store.SavePayrollRun(&run); err != nil {
+		log.Println(err)
+	}
+	utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), "Payroll run previewed")
+}
+
+// PayrollLockController freeze a previewed payroll run ...
+func PayrollLockController(res http.ResponseWriter, req *http.Request) {
+	run, hr, ok := getPayrollRun(res, req)
+	if !ok {
+		return
+	}
+	if run.Status != models.RunPreviewed {
+		utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), "Only a previewed payroll run can be locked")
+		return
+	}
+	run.Status = models.RunLocked
+	run.LockedBy = hr.Email
+	run.LockedOn = time.Now()
+	if err := store.SavePayrollRun(&run); err != nil {
+		log.Println(err)
+	}
+	utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), "Payroll run locked")
+}
+
+// PayrollPublishController generate the PDF of every payslip in a locked run and
+// approve it, so employees can download it ...
+func PayrollPublishController(res http.ResponseWriter, req *http.Request) {
+	run, hr, ok := getPayrollRun(res, req)
+	if !ok {
+		return
+	}
+	if run.Status != models.RunLocked {
+		utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), "Only a locked payroll run can be published")
+		return
+	}
+	payslips, err := store.GetPayslipsOfRun(run.RunID)
+	if err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), "Could not load payslips of the run")
+		return
+	}
+	byUser := make(map[string]models.Payslip)
+	for _, payslip := range payslips {
+		byUser[payslip.Requestor.UserID] = payslip
+	}
+	approver := hr
+	approver.AccessToken = ""
+	employees := make([]models.Employee, len(run.Results))
+	for i, result := range run.Results {
+		employees[i] = models.Employee{UserID: result.UserID, EmployeeNo: result.EmployeeNo, Name: result.Name}
+	}
+	run.Results = payroll.RunEach(employees, payrollWorkers(), func(employee models.Employee) models.PayrollResult {
+		payslip, ok := byUser[employee.UserID]
+		if !ok {
+			return payroll.ResultOf(employee, payslip, errNoPayslip)
+		}
+		if payslip.Status != models.PayslipDraft {
+			return payroll.ResultOf(employee, payslip, nil)
+		}
+		if _, err := generatePayslipPDF(&payslip); err != nil {
+			return payroll.ResultOf(employee, payslip, err)
+		}
+		payslip.Status = models.PayslipApproved
+		payslip.Approver = approver
+		payslip.ActedOn = time.Now()
+		err := store.UpdatePayslipStatus(&payslip, models.PayslipDraft)
+		return payroll.ResultOf(employee, payslip, err)
+	})
+	run.Status = models.RunPublished
+	run.PublishedBy = hr.Email
+	run.PublishedOn = time.Now()
+	if err = store.SavePayrollRun(&run); err != nil {
+		log.Println(err)
+	}
+	utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), "Payroll run published")
+}
+
+// PayrollBankExportController download the salary transfer file for the month of a
+// payroll run, every approved payslip of the month is paid by it ...
+func PayrollBankExportController(res http.ResponseWriter, req *http.Request) {
+	run, _, ok := getPayrollRun(res, req)
+	if !ok {
+		return
+	}
+	format, ok := bankexport.Lookup(req.URL.Query().Get(":format"))
+	if !ok {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return
+	}
+	payslips, err := store.GetApprovedPayslipsFor(models.OrgIDOr(run.OrgID), run.Month)
+	if err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), "Could not load payslips of the month")
+		return
+	}
+	organisation, err := store.GetOrganisation(models.OrgIDOr(run.OrgID))
+	if err != nil {
+		log.Println(err)
+	}
+	debitAccount := organisation.DebitAccount
+	if debitAccount == "" {
+		debitAccount = os.Getenv("bc_bank_debit_account")
+	}
+	var control bankexport.Control
+	control.Count, control.Net, err = store.GetApprovedTotalsFor(models.OrgIDOr(run.OrgID), run.Month)
+	if err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), "Could not total the payslips of the month")
+		return
+	}
+	batch, err := bankexport.Build(payslips, run.Month, debitAccount, control)
+	if recordErr, ok := err.(*bankexport.RecordError); ok {
+		problems := recordErr.Problems
+		if len(problems) > 5 {
+			problems = append(problems[:5], strconv.Itoa(len(recordErr.Problems)-5)+" more")
+		}
+		utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), "Fix these payslips before exporting: "+strings.Join(problems, "; "))
+		return
+	}
+	if err != nil {
+		utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), err.Error())
+		return
+	}
+	var file strings.Builder
+	if err = format.Write(&file, batch); err != nil {
+		utils.RedirectWithMessage(res, req, payrollRunPath(run.RunID), err.Error())
+		return
+	}
+	fileName := "salary-" + run.Period + "-" + format.Name() + format.Extension()
+	res.Header().Set("Content-Type", format.ContentType())
+	res.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	res.Write([]byte(file.String()))
+}
@@ -1,15 +1,21 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"bcpayslip/helpers"
 	"bcpayslip/models"
+	"bcpayslip/payroll"
+	"bcpayslip/statutory"
 	"bcpayslip/store"
 	"bcpayslip/templates"
 	"bcpayslip/urls"
 	"bcpayslip/utils"
+	"bcpayslip/validators"
 
 	"github.com/gorilla/context"
 	"github.com/gorilla/schema"
@@ -20,26 +26,154 @@ import (
 func PayslipController(res http.ResponseWriter, req *http.Request) {
 	data := make(map[string]interface{})
 	controllerTemplate := templates.PayslipTemplate
+	data["states"] = statutory.States
+	data["errors"] = validators.Errors{}
+	employee, employeeErr := store.GetEmployee(context.Get(req, "userid").(string))
+	data["hasEmployee"] = employeeErr == nil
+	data["grossFromRecord"] = employeeErr == nil && employee.MonthlyGross > 0
+	data["declarationFromRecord"] = employeeErr == nil && employee.Declaration != (models.TaxDeclaration{})
+	payslip := new(models.Payslip)
+	if employeeErr == nil {
+		payroll.ApplyEmployee(payslip, employee)
+		payroll.ApplyEmployeePay(payslip, employee)
+	}
+	data["form"] = payslip
 	if req.Method == "GET" {
 		utils.CustomTemplateExecute(res, req, controllerTemplate, data)
 	}
 	if req.Method == "POST" {
-		err := req.ParseForm()
-		payslip := new(models.Payslip)
+		if err := req.ParseForm(); err != nil {
+			utils.RedirectWithMessage(res, req, urls.PayslipPath, "Invalid form")
+			return
+		}
 		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
 		decoder.RegisterConverter(time.Time{}, helpers.ConvertFormDate)
-		err = decoder.Decode(payslip, req.Form)
-		if err != nil {
-			http.Redirect(res, req, urls.HomePath, http.StatusSeeOther)
+		errors := validators.Errors{}
+		var form helpers.PayslipForm
+		if err := decoder.Decode(&form, req.PostForm); err != nil {
+			if fieldErrors, ok := err.(schema.MultiError); ok {
+				for field := range fieldErrors {
+					errors[field] = "Enter a valid value"
+				}
+			} else {
+				errors["form"] = err.Error()
+			}
 		}
+		// a new payslip, nothing of it is taken from the form but the entered fields
+		payslip = new(models.Payslip)
+		form.Apply(payslip)
+		data["form"] = payslip
 		user, _ := store.GetUser(context.Get(req, "userid").(string))
 		payslip.Requestor = user
+		payslip.Requestor.AccessToken = ""
 		payslip.RequestedOn = time.Now()
-		payslip.Status = 0
+		payslip.Status = models.PayslipSubmitted
+		if req.PostForm.Get("action") == "draft" {
+			payslip.Status = models.PayslipDraft
+		}
 		payslip.PayslipID = user.UserID
+		payslip.OrgID = models.DefaultOrgID
+		if employeeErr == nil {
+			payroll.ApplyEmployee(payslip, employee)
+			payroll.ApplyEmployeePay(payslip, employee)
+		}
+		// paid days follow from the working and LOP days entered and the dates of
+		// joining and leaving
+		payroll.ApplyAttendance(payslip, models.MonthlyInput{WorkingDays: payslip.WorkingDays, LOPDays: payslip.LOPDays})
+		payslip.Name = strings.TrimSpace(payslip.Name)
+		payslip.AccountNo = strings.Replace(payslip.AccountNo, " ", "", -1)
+		payslip.IFSCCode = strings.ToUpper(strings.TrimSpace(payslip.IFSCCode))
+		if len(errors) == 0 {
+			errors = validators.ValidatePayslip(payslip)
+		}
+		if len(errors) == 0 {
+			structure, err := store.GetSalaryStructureFor(user.UserID, payslip.OrgID, payslip.Grade)
+			if err != nil {
+				structure = payroll.DefaultStructure()
+			}
+			if err = payroll.Calculate(payslip, structure); err != nil {
+				errors["form"] = "Could not calculate payslip: " + err.Error()
+			} else if err = setExchangeRate(payslip); err != nil {
+				errors["form"] = "Could not calculate payslip: " + err.Error()
+			} else {
+				errors = validators.ValidatePayslip(payslip)
+			}
+		}
+		if len(errors) > 0 {
+			data["errors"] = errors
+			data["message"] = "Please correct the highlighted fields"
+			if message, ok := errors["form"]; ok {
+				data["message"] = message
+			}
+			res.WriteHeader(http.StatusUnprocessableEntity)
+			utils.CustomTemplateExecute(res, req, controllerTemplate, data)
+			return
+		}
 		uuidNew := uuid.Must(uuid.NewV4(), nil)
 		payslip.UUID = uuidNew.String()
-		helpers.GeneratePayslipPDF(payslip)
-		http.Redirect(res, req, "/media/"+payslip.UUID+".pdf", http.StatusSeeOther)
+		if err := store.SavePayslip(payslip); err != nil {
+			log.Println(err)
+			utils.RedirectWithMessage(res, req, urls.PayslipPath, "Could not save payslip, try again")
+			return
+		}
+		message := "Payslip submitted for approval"
+		if payslip.Status == models.PayslipDraft {
+			message = "Payslip saved as draft"
+		}
+		utils.RedirectWithMessage(res, req, urls.PayslipsPath, message)
+	}
+}
+
+// getOwnPayslip Fetch the payslip in the url and make sure it belongs to the logged in user ...
+func getOwnPayslip(req *http.Request) (models.Payslip, bool) {
+	payslip, err := store.GetPayslip(req.URL.Query().Get(":uuid"))
+	if err != nil {
+		return payslip, false
+	}
+	return payslip, payslip.Requestor.UserID == context.Get(req, "userid").(string)
+}
+
+// SubmitPayslipController submit a draft payslip for approval ...
+func SubmitPayslipController(res http.ResponseWriter, req *http.Request) {
+	payslip, ok := getOwnPayslip(req)
+	if !ok {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return
+	}
+	if !payslip.Status.CanTransitionTo(models.PayslipSubmitted) {
+		utils.RedirectWithMessage(res, req, urls.PayslipsPath, "Only draft payslips can be submitted")
+		return
+	}
+	payslip.Status = models.PayslipSubmitted
+	payslip.RequestedOn = time.Now()
+	if err := store.UpdatePayslipStatus(&payslip, models.PayslipDraft); err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, urls.PayslipsPath, "Could not submit payslip, try again")
+		return
+	}
+	utils.RedirectWithMessage(res, req, urls.PayslipsPath, "Payslip submitted for approval")
+}
+
+// payslipsPerPage number of payslips listed on a history page ...
+const payslipsPerPage int = 10
+
+// PayslipHistoryController list payslips generated by the logged in user ...
+func PayslipHistoryController(res http.ResponseWriter, req *http.Request) {
+	data := make(map[string]interface{})
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	payslips, total, err := store.GetPayslips(context.Get(req, "userid").(string), page, payslipsPerPage)
+	if err != nil {
+		log.Println(err)
 	}
+	data["payslips"] = payslips
+	// payslips are issued by the organisation the employee belongs to
+	employee, _ := store.GetEmployee(context.Get(req, "userid").(string))
+	data["pdfPassword"] = pdfPasswordHint(employee.OrgID)
+	data["page"] = page
+	data["previousPage"], data["nextPage"] = utils.PageNeighbours(page, payslipsPerPage, total)
+	utils.CustomTemplateExecute(res, req, templates.PayslipHistoryTemplate, data)
 }
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bcpayslip/currency"
+	"bcpayslip/helpers"
+	"bcpayslip/models"
+	"bcpayslip/statutory"
+	"bcpayslip/store"
+	"bcpayslip/templates"
+	"bcpayslip/urls"
+	"bcpayslip/utils"
+	"bcpayslip/validators"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/schema"
+)
+
+// EmployeesController list users with their employee records for HR ...
+func EmployeesController(res http.ResponseWriter, req *http.Request) {
+	if _, ok := getHR(req); !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can manage employee records")
+		return
+	}
+	data := make(map[string]interface{})
+	users, err := store.GetUsers()
+	if err != nil {
+		log.Println(err)
+	}
+	employees, err := store.GetEmployees(utils.CurrentOrgID(req))
+	if err != nil {
+		log.Println(err)
+	}
+	records := make(map[string]models.Employee)
+	for _, employee := range employees {
+		records[employee.UserID] = employee
+	}
+	orgIDs, err := store.GetEmployeeOrgIDs()
+	if err != nil {
+		log.Println(err)
+	}
+	// users of other organisations are left out, users without a record can be
+	// added to this one
+	var listed []models.User
+	for _, user := range users {
+		if orgID, ok := orgIDs[user.UserID]; !ok || orgID == utils.CurrentOrgID(req) {
+			listed = append(listed, user)
+		}
+	}
+	data["users"] = listed
+	data["employees"] = records
+	utils.CustomTemplateExecute(res, req, templates.EmployeesTemplate, data)
+}
+
+// ProfileViewController show the employee record of a user to themselves or HR ...
+func ProfileViewController(res http.ResponseWriter, req *http.Request) {
+	viewer, _ := store.GetUser(context.Get(req, "userid").(string))
+	userID := req.URL.Query().Get(":userid")
+	isHR := utils.HasRole(viewer, models.RoleHR)
+	if userID != viewer.UserID && !isHR {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return
+	}
+	profile, err := store.GetUser(userID)
+	if err != nil {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return
+	}
+	data := make(map[string]interface{})
+	employee, err := store.GetEmployee(userID)
+	data["profile"] = profile
+	data["employee"] = employee
+	data["hasEmployee"] = err == nil
+	if data["organisation"], err = store.GetOrganisation(models.OrgIDOr(employee.OrgID)); err != nil {
+		log.Println(err)
+	}
+	data["canEdit"] = isHR
+	utils.CustomTemplateExecute(res, req, templates.ProfileViewTemplate, data)
+}
+
+// ProfileEditController let HR create or edit the employee record of a user ...
+func ProfileEditController(res http.ResponseWriter, req *http.Request) {
+	hr, ok := getHR(req)
+	if !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can edit employee records")
+		return
+	}
+	userID := req.URL.Query().Get(":userid")
+	profile, err := store.GetUser(userID)
+	if err != nil {
+		http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+		return
+	}
+	employee, err := store.GetEmployee(userID)
+	if err != nil {
+		employee = models.Employee{UserID: userID, Name: strings.TrimSpace(profile.FirstName + " " + profile.LastName), Active: true, OrgID: utils.CurrentOrgID(req)}
+	}
+	employee.OrgID = models.OrgIDOr(employee.OrgID)
+	organisations, err := store.GetOrganisations()
+	if err != nil {
+		log.Println(err)
+	}
+	profilePath := utils.AddParamsToURL(urls.ProfilePath, []models.Kwargs{{Key: "userid", Value: userID}})
+	if req.Method == "POST" {
+		if err = req.ParseForm(); err != nil {
+			utils.RedirectWithMessage(res, req, profilePath, "Invalid form")
+			return
+		}
+		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		decoder.RegisterConverter(time.Time{}, helpers.ConvertFormDate)
+		// unticked checkboxes are not posted
+		employee.Active = false
+		employee.Declaration.SeniorParents = false
+		employee.Declaration.MetroCity = false
+		if err = decoder.Decode(&employee, req.PostForm); err != nil {
+			utils.RedirectWithMessage(res, req, profilePath, "Invalid form")
+			return
+		}
+		employee.UserID = userID
+		employee.PAN = strings.ToUpper(strings.TrimSpace(employee.PAN))
+		employee.IFSCCode = strings.ToUpper(strings.TrimSpace(employee.IFSCCode))
+		employee.AccountNo = strings.Replace(employee.AccountNo, " ", "", -1)
+		employee.UAN = strings.TrimSpace(employee.UAN)
+		errors := validators.ValidateEmployee(&employee)
+		if _, err = store.GetOrganisation(employee.OrgID); err != nil {
+			errors["OrgID"] = "Pick an organisation"
+		}
+		if len(errors) > 0 {
+			data := make(map[string]interface{})
+			data["profile"] = profile
+			data["employee"] = employee
+			data["states"] = statutory.States
+			data["organisations"] = organisations
+			data["currencies"] = currency.All()
+			data["errors"] = errors
+			data["message"] = "Please correct the highlighted fields"
+			res.WriteHeader(http.StatusUnprocessableEntity)
+			utils.CustomTemplateExecute(res, req, templates.ProfileEditTemplate, data)
+			return
+		}
+		employee.UpdatedBy = hr.Email
+		employee.UpdatedOn = time.Now()
+		if err = store.SaveEmployee(&employee); err != nil {
+			log.Println(err)
+			utils.RedirectWithMessage(res, req, profilePath, "Could not save employee record")
+			return
+		}
+		utils.RedirectWithMessage(res, req, profilePath, "Employee record saved")
+		return
+	}
+	data := make(map[string]interface{})
+	data["profile"] = profile
+	data["employee"] = employee
+	data["states"] = statutory.States
+	data["organisations"] = organisations
+	data["currencies"] = currency.All()
+	data["errors"] = validators.Errors{}
+	utils.CustomTemplateExecute(res, req, templates.ProfileEditTemplate, data)
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bcpayslip/models"
+	"bcpayslip/money"
+	"bcpayslip/payroll"
+	"bcpayslip/store"
+	"bcpayslip/templates"
+	"bcpayslip/urls"
+	"bcpayslip/utils"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/schema"
+	uuid "github.com/satori/go.uuid"
+)
+
+// newStructureID Placeholder id in the url for a structure that is not saved yet ...
+const newStructureID string = "new"
+
+// getHR Return the logged in user if they manage salary data ...
+func getHR(req *http.Request) (models.User, bool) {
+	user, err := store.GetUser(context.Get(req, "userid").(string))
+	if err != nil {
+		return user, false
+	}
+	return user, utils.HasRole(user, models.RoleHR)
+}
+
+// StructuresController list salary structures and their assignments ...
+func StructuresController(res http.ResponseWriter, req *http.Request) {
+	if _, ok := getHR(req); !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can manage salary structures")
+		return
+	}
+	data := make(map[string]interface{})
+	structures, err := store.GetSalaryStructures(utils.CurrentOrgID(req))
+	if err != nil {
+		log.Println(err)
+	}
+	assignments, err := store.GetStructureAssignments(utils.CurrentOrgID(req))
+	if err != nil {
+		log.Println(err)
+	}
+	names := make(map[string]string)
+	for _, structure := range structures {
+		names[structure.StructureID] = structure.Name
+	}
+	data["structures"] = structures
+	data["assignments"] = assignments
+	data["structureNames"] = names
+	utils.CustomTemplateExecute(res, req, templates.SalaryStructuresTemplate, data)
+}
+
+// StructureController create or edit a salary structure ...
+func StructureController(res http.ResponseWriter, req *http.Request) {
+	user, ok := getHR(req)
+	if !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can manage salary structures")
+		return
+	}
+	data := make(map[string]interface{})
+	structureID := req.URL.Query().Get(":id")
+	structure := payroll.DefaultStructure()
+	structure.Name = ""
+	structure.OrgID = utils.CurrentOrgID(req)
+	if structureID != newStructureID {
+		var err error
+		structure, err = store.GetSalaryStructure(structureID)
+		if err != nil || models.OrgIDOr(structure.OrgID) != utils.CurrentOrgID(req) {
+			http.Redirect(res, req, urls.NotfoundPath, http.StatusSeeOther)
+			return
+		}
+	}
+	if req.Method == "POST" {
+		if err := req.ParseForm(); err != nil {
+			utils.RedirectWithMessage(res, req, urls.StructuresPath, "Invalid form")
+			return
+		}
+		posted := models.SalaryStructure{}
+		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		if err := decoder.Decode(&posted, req.PostForm); err != nil {
+			utils.RedirectWithMessage(res, req, urls.StructuresPath, "Invalid form")
+			return
+		}
+		structure.Name = strings.TrimSpace(posted.Name)
+		structure.Components = nil
+		for _, component := range posted.Components {
+			if strings.TrimSpace(component.Name) == "" {
+				continue
+			}
+			component.Code = strings.ToLower(strings.TrimSpace(component.Code))
+			structure.Components = append(structure.Components, component)
+		}
+		if err := payroll.ValidateStructure(structure); err != nil {
+			data["message"] = err.Error()
+		} else {
+			if structureID == newStructureID {
+				structure.StructureID = uuid.Must(uuid.NewV4(), nil).String()
+			}
+			structure.UpdatedBy = user.Email
+			structure.UpdatedOn = time.Now()
+			if err = store.SaveSalaryStructure(&structure); err != nil {
+				log.Println(err)
+				data["message"] = "Could not save salary structure"
+			} else {
+				utils.RedirectWithMessage(res, req, urls.StructuresPath, "Salary structure saved")
+				return
+			}
+		}
+	}
+	if preview, err := payroll.ComputeEarnings(structure, money.Rupees(100000)); err == nil {
+		data["preview"] = preview
+	}
+	// blank rows for adding components
+	rows := append([]models.SalaryComponent{}, structure.Components...)
+	for i := 0; i < 3; i++ {
+		rows = append(rows, models.SalaryComponent{Type: models.ComponentPercentage, Of: payroll.GrossCode})
+	}
+	data["structure"] = structure
+	data["rows"] = rows
+	data["structureID"] = structureID
+	data["componentTypes"] = []string{models.ComponentPercentage, models.ComponentFixed, models.ComponentFormula}
+	utils.CustomTemplateExecute(res, req, templates.SalaryStructureTemplate, data)
+}
+
+// AssignStructureController assign a salary structure to a user by email or to a grade ...
+func AssignStructureController(res http.ResponseWriter, req *http.Request) {
+	if _, ok := getHR(req); !ok {
+		utils.RedirectWithMessage(res, req, urls.HomePath, "Only HR can manage salary structures")
+		return
+	}
+	assignment := models.StructureAssignment{
+		Grade:       strings.TrimSpace(req.FormValue("Grade")),
+		StructureID: req.FormValue("StructureID"),
+		OrgID:       utils.CurrentOrgID(req),
+	}
+	if assignment.StructureID != "" {
+		structure, err := store.GetSalaryStructure(assignment.StructureID)
+		if err != nil || models.OrgIDOr(structure.OrgID) != assignment.OrgID {
+			utils.RedirectWithMessage(res, req, urls.StructuresPath, "Pick a salary structure of this organisation")
+			return
+		}
+	}
+	if email := strings.TrimSpace(req.FormValue("Email")); email != "" {
+		user, err := store.GetUserByEmail(email)
+		if err != nil {
+			utils.RedirectWithMessage(res, req, urls.StructuresPath, "No user with email "+email)
+			return
+		}
+		assignment.UserID = user.UserID
+		assignment.Email = user.Email
+		assignment.Grade = ""
+	} else if assignment.Grade == "" {
+		utils.RedirectWithMessage(res, req, urls.StructuresPath, "Enter an email or a grade")
+		return
+	}
+	if err := store.AssignSalaryStructure(assignment); err != nil {
+		log.Println(err)
+		utils.RedirectWithMessage(res, req, urls.StructuresPath, "Could not assign salary structure")
+		return
+	}
+	utils.RedirectWithMessage(res, req, urls.StructuresPath, "Salary structure assignment saved")
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"bcpayslip/helpers"
+	"bcpayslip/models"
+	"bcpayslip/money"
+	"bcpayslip/store"
+	"bcpayslip/templates"
+	"bcpayslip/urls"
+	"bcpayslip/utils"
+)
+
+// maxVerifySize Largest PDF that can be checked on the verification page ...
+const maxVerifySize = 5 << 20
+
+// netPayChecks Net pay checks allowed per payslip and per address, the check
+// answers whether a guessed net pay is right ...
+var netPayChecks = utils.NewLimiter(5, time.Hour)
+
+// remoteHost Address of the client without its port ...
+func remoteHost(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// VerifyFormController ask for the code printed on a payslip ...
+func VerifyFormController(res http.ResponseWriter, req *http.Request) {
+	if code := helpers.NormalizeVerificationCode(req.URL.Query().Get("code")); code != "" {
+		http.Redirect(res, req, utils.AddParamsToURL(urls.VerifyPath, []models.Kwargs{{Key: "code", Value: code}}), http.StatusSeeOther)
+		return
+	}
+	t, _ := template.ParseFiles(templates.VerifyTemplate)
+	t.Execute(res, map[string]interface{}{})
+}
+
+// VerifyController public page that confirms a payslip was issued, showing who it
+// is for, its period and a fingerprint of its net pay. A PDF can be uploaded to
+// check it against the stored signature, and a net pay against the fingerprint ...
+func VerifyController(res http.ResponseWriter, req *http.Request) {
+	code := helpers.NormalizeVerificationCode(req.URL.Query().Get(":code"))
+	data := map[string]interface{}{"code": helpers.FormatVerificationCode(code)}
+	t, _ := template.ParseFiles(templates.VerifyTemplate)
+	payslip, err := store.GetPayslipByVerificationCode(code)
+	if err != nil || !payslip.Status.IsDownloadable() || payslip.Signature == "" {
+		res.WriteHeader(http.StatusNotFound)
+		data["unknown"] = true
+		t.Execute(res, data)
+		return
+	}
+	organisation, err := store.GetOrganisation(models.OrgIDOr(payslip.OrgID))
+	if err != nil {
+		log.Println(err)
+	}
+	data["payslip"] = payslip
+	data["organisation"] = organisation
+	data["netPayHash"] = helpers.NetPayHash(payslip.VerificationCode, payslip.AmountReceivedBank)
+	if req.Method == "POST" {
+		req.Body = http.MaxBytesReader(res, req.Body, maxVerifySize)
+		if err = req.ParseMultipartForm(maxVerifySize); err != nil {
+			data["fileError"] = "The file is too large to be a payslip"
+			t.Execute(res, data)
+			return
+		}
+		if file, _, err := req.FormFile("File"); err == nil {
+			defer file.Close()
+			pdf, err := ioutil.ReadAll(file)
+			if err == nil {
+				err = helpers.VerifyPayslipPDF(pdf, payslip.SignatureKey, payslip.Signature)
+			}
+			if err != nil {
+				data["fileError"] = err.Error()
+			} else {
+				data["fileOK"] = true
+			}
+		}
+		if value := strings.TrimSpace(req.FormValue("NetPay")); value != "" {
+			now := time.Now()
+			// both limits count every check so guesses are refused from anywhere
+			byCode := netPayChecks.Allow("code:"+payslip.VerificationCode, now)
+			byAddress := netPayChecks.Allow("addr:"+remoteHost(req), now)
+			if !byCode || !byAddress {
+				res.WriteHeader(http.StatusTooManyRequests)
+				data["netPayError"] = "Too many net pay checks, try again in an hour"
+				t.Execute(res, data)
+				return
+			}
+			netPay, err := money.Parse(value)
+			data["netPayChecked"] = true
+			data["netPayOK"] = err == nil && helpers.NetPayHash(payslip.VerificationCode, netPay) == data["netPayHash"]
+		}
+	}
+	t.Execute(res, data)
+}
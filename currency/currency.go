@@ -0,0 +1,140 @@
+// Package currency formats amounts the way readers of each currency expect them,
+// rupees in lakhs and crores and most other currencies in thousands, and converts
+// foreign amounts to rupees, the currency the books are kept in.
+package currency
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bcpayslip/money"
+)
+
+// Base Currency of the books, payslips in other currencies show their value in it ...
+const Base = "INR"
+
+// Digit grouping of the whole part of an amount ...
+const (
+	// Thousands 1,234,567
+	Thousands = iota
+	// Indian 12,34,567, the last three digits and then pairs
+	Indian
+)
+
+// Currency How amounts of a currency are written ...
+type Currency struct {
+	Code     string
+	Name     string
+	Decimals int
+	Grouping int
+	Decimal  string
+	Group    string
+}
+
+// currencies Currencies employees can be paid in by code ...
+var currencies = map[string]Currency{
+	"INR": {Code: "INR", Name: "Indian Rupee", Decimals: 2, Grouping: Indian, Decimal: ".", Group: ","},
+	"USD": {Code: "USD", Name: "US Dollar", Decimals: 2, Grouping: Thousands, Decimal: ".", Group: ","},
+	"GBP": {Code: "GBP", Name: "Pound Sterling", Decimals: 2, Grouping: Thousands, Decimal: ".", Group: ","},
+	"EUR": {Code: "EUR", Name: "Euro", Decimals: 2, Grouping: Thousands, Decimal: ",", Group: "."},
+	"SGD": {Code: "SGD", Name: "Singapore Dollar", Decimals: 2, Grouping: Thousands, Decimal: ".", Group: ","},
+	"AED": {Code: "AED", Name: "UAE Dirham", Decimals: 2, Grouping: Thousands, Decimal: ".", Group: ","},
+	"AUD": {Code: "AUD", Name: "Australian Dollar", Decimals: 2, Grouping: Thousands, Decimal: ".", Group: ","},
+	"CAD": {Code: "CAD", Name: "Canadian Dollar", Decimals: 2, Grouping: Thousands, Decimal: ".", Group: ","},
+	"JPY": {Code: "JPY", Name: "Japanese Yen", Decimals: 0, Grouping: Thousands, Decimal: ".", Group: ","},
+}
+
+// Lookup Find a currency by code, records without a currency are in rupees ...
+func Lookup(code string) (Currency, bool) {
+	if code == "" {
+		code = Base
+	}
+	c, ok := currencies[code]
+	return c, ok
+}
+
+// CodeOr The currency of a record, records saved before there were several
+// currencies are in rupees ...
+func CodeOr(code string) string {
+	if code == "" {
+		return Base
+	}
+	return code
+}
+
+// IsForeign Whether amounts in the currency are not rupees ...
+func IsForeign(code string) bool {
+	return CodeOr(code) != Base
+}
+
+// All Currencies employees can be paid in, rupees first and the rest by code ...
+func All() []Currency {
+	var list []Currency
+	for _, c := range currencies {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Code == Base || list[j].Code == Base {
+			return list[i].Code == Base
+		}
+		return list[i].Code < list[j].Code
+	})
+	return list
+}
+
+// Format Write an amount with the digit grouping and decimal mark of the currency,
+// currencies without minor units are rounded to whole units ...
+func (c Currency) Format(amount money.Amount) string {
+	hundredths := int64(amount)
+	sign := ""
+	if hundredths < 0 {
+		sign, hundredths = "-", -hundredths
+	}
+	if c.Decimals == 0 {
+		hundredths = (hundredths + 50) / 100 * 100
+	}
+	if hundredths == 0 {
+		sign = ""
+	}
+	text := sign + group(strconv.FormatInt(hundredths/100, 10), c.Grouping, c.Group)
+	if c.Decimals > 0 {
+		text += c.Decimal + fmt.Sprintf("%02d", hundredths%100)
+	}
+	return text
+}
+
+// group Separate the digits of a whole number ...
+func group(digits string, grouping int, separator string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	head, tail := digits[:len(digits)-3], digits[len(digits)-3:]
+	size := 3
+	if grouping == Indian {
+		size = 2
+	}
+	var parts []string
+	for len(head) > size {
+		parts = append([]string{head[len(head)-size:]}, parts...)
+		head = head[:len(head)-size]
+	}
+	parts = append([]string{head}, parts...)
+	return strings.Join(append(parts, tail), separator)
+}
+
+// Format Write an amount in a currency, unknown currencies are written like rupees ...
+func Format(amount money.Amount, code string) string {
+	c, ok := Lookup(code)
+	if !ok {
+		c = currencies[Base]
+	}
+	return c.Format(amount)
+}
+
+// ToBase Value of an amount in rupees at a rate of rupees per unit of the currency,
+// rounded to the paisa ...
+func ToBase(amount money.Amount, rate float64) money.Amount {
+	return amount.Times(rate)
+}
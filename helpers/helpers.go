@@ -1,22 +1,40 @@
 package helpers
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
+	"bcpayslip/currency"
+	"bcpayslip/layout"
 	"bcpayslip/models"
-
-	"github.com/jung-kurt/gofpdf"
+	"bcpayslip/money"
+	"bcpayslip/payroll"
 )
 
 // ImageToBase64 Convert url image to base64 encoding ...
 func ImageToBase64(url string) string {
-	res, _ := http.Get(url)
+	if url == "" {
+		return ""
+	}
+	res, err := http.Get(url)
+	if err != nil {
+		return ""
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return ""
+	}
 	bodyBytes, _ := ioutil.ReadAll(res.Body)
 	imgBase64Str := base64.StdEncoding.EncodeToString(bodyBytes)
 	return imgBase64Str
@@ -28,136 +46,216 @@ func ConvertFormDate(value string) reflect.Value {
 	return reflect.ValueOf(s.UTC())
 }
 
-// GeneratePayslipPDF generate PDF for payslip ...
-func GeneratePayslipPDF(payslip *models.Payslip) error {
-	salary := payslip.GrossAnnualSalary
-	var floatInt int
-	floatInt = 2
-	var floatType int
-	floatType = 64
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetX(-60)
-	pdf.SetFont("Arial", "", 16)
-	pdf.SetTextColor(26, 162, 251)
-	pdf.Cell(30, 0, "BEAUTIFUL ")
-	pdf.SetFont("Arial", "B", 16)
-	pdf.Cell(30, 0, " CODE")
-	pdf.SetTextColor(0, 0, 0)
-	pdf.SetFontSize(10)
-	pdf.SetXY(100, 30)
-	pdf.Line(10, 20, 200, 20)
-	pdf.Line(10, 40, 200, 40)
-	pdf.Cell(100, 0, "Pay Slip")
-	pdf.Line(10, 20, 10, 40)
-	pdf.Line(200, 20, 200, 40)
-	pdf.SetXY(100, 40)
-	pdf.Line(10, 40, 200, 40)
-	pdf.Line(10, 70, 200, 70)
-	pdf.Line(10, 40, 10, 70)
-	pdf.Line(200, 40, 200, 70)
-	pdf.SetXY(20, 40)
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(40, 10, "Pay Period: ")
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(40, 10, payslip.Month.Format(time.RFC1123)[8:16])
-	pdf.SetXY(100, 40)
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(40, 10, "Pay Date: ")
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(40, 10, payslip.Day.Format(time.RFC1123)[5:16])
-	pdf.SetXY(20, 50)
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(40, 10, "Employee Name: ")
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(40, 10, payslip.Name)
-	pdf.SetXY(100, 50)
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(40, 10, "Position: ")
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(40, 10, payslip.Position)
-	pdf.SetXY(20, 60)
-	if strings.Replace(payslip.EmployeeNo, " ", "", -1) != "" {
-		pdf.SetFont("Arial", "B", 10)
-		pdf.Cell(40, 10, "Employee No: ")
-		pdf.SetFont("Arial", "", 10)
-		pdf.Cell(40, 10, payslip.EmployeeNo)
-	}
-	pdf.SetXY(100, 70)
-	pdf.Line(10, 70, 200, 70)
-	pdf.Line(10, 120, 200, 120)
-	pdf.Line(10, 70, 10, 120)
-	pdf.Line(120, 70, 120, 120)
-	pdf.Line(200, 70, 200, 120)
-	pdf.SetXY(20, 70)
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(70, 10, "Earnings & Allowances")
-	pdf.Cell(30, 10, "INR")
-	pdf.SetXY(20, 80)
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(70, 10, "Basic Salary")
-	pdf.Cell(30, 10, strconv.FormatFloat((salary*0.6), 'f', floatInt, floatType))
-	pdf.SetXY(20, 90)
-	pdf.Cell(70, 10, "House Rent Allowance")
-	pdf.Cell(30, 10, strconv.FormatFloat((salary*0.2), 'f', floatInt, floatType))
-	pdf.SetXY(20, 100)
-	pdf.Cell(70, 10, "Spcial / Conv Allowance")
-	pdf.Cell(30, 10, strconv.FormatFloat((salary*0.15), 'f', floatInt, floatType))
-	pdf.SetXY(20, 110)
-	pdf.Cell(70, 10, "Other Allowance")
-	pdf.Cell(30, 10, strconv.FormatFloat((salary*0.05), 'f', floatInt, floatType))
-	pdf.SetXY(120, 70)
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(40, 10, "Deductions")
-	pdf.Cell(20, 10, "INR")
-	pdf.SetXY(120, 80)
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(40, 10, "Income Tax")
-	pdf.Cell(20, 10, strconv.FormatFloat(salary-payslip.AmountReceivedBank, 'f', floatInt, floatType))
-	pdf.SetXY(120, 90)
-	pdf.Cell(40, 10, "Advance")
-	pdf.Cell(20, 10, "0.00")
-	pdf.SetXY(120, 100)
-	pdf.Cell(40, 10, "Profession Tax")
-	pdf.Cell(20, 10, "0.00")
-	pdf.SetXY(100, 120)
-	pdf.Line(10, 120, 200, 120)
-	pdf.Line(10, 160, 200, 160)
-	pdf.Line(10, 120, 10, 160)
-	pdf.Line(120, 120, 120, 160)
-	pdf.Line(200, 120, 200, 160)
-	pdf.SetXY(20, 120)
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(20, 10, "Bank Account: ")
-	pdf.SetXY(20, 130)
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(40, 10, "Account No: ")
-	pdf.Cell(50, 10, payslip.AccountNo)
-	pdf.SetXY(20, 140)
-	pdf.Cell(40, 10, "IFSC Code: ")
-	pdf.Cell(50, 10, payslip.IFSCCode)
-	pdf.SetXY(120, 120)
-	pdf.SetFont("Arial", "B", 10)
-	pdf.Cell(40, 10, "Pay Summary")
-	pdf.Cell(30, 10, "INR")
-	pdf.SetXY(120, 130)
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(40, 10, "Total Gross")
-	pdf.Cell(20, 10, strconv.FormatFloat((salary), 'f', floatInt, floatType))
-	pdf.SetXY(120, 140)
-	pdf.Cell(40, 10, "Deductions")
-	pdf.Cell(20, 10, strconv.FormatFloat(((salary)-payslip.AmountReceivedBank), 'f', floatInt, floatType))
-	pdf.SetXY(120, 150)
-	pdf.Cell(40, 10, "NET PAY")
-	pdf.Cell(20, 10, strconv.FormatFloat(payslip.AmountReceivedBank, 'f', floatInt, floatType))
-	pdf.SetXY(10, 160)
-	pdf.Line(10, 190, 200, 190)
-	pdf.Line(10, 160, 10, 190)
-	pdf.Line(200, 160, 200, 190)
-	pdf.SetXY(75, 170)
-	pdf.Cell(150, 10, "(*) denotes back pay adjustment")
-	pdf.SetXY(75, 180)
-	pdf.Cell(150, 10, "Computer Generated Form does not require signature")
-	err := pdf.OutputFileAndClose("media/" + payslip.UUID + ".pdf")
-	return err
+// PayslipPDFPath Location of a generated payslip PDF on disk ...
+func PayslipPDFPath(uuid string) string {
+	return "media/" + uuid + ".pdf"
+}
+
+// SignPayslipLink Sign a payslip uuid and expiry so the payslip can be shared without a login ...
+func SignPayslipLink(uuid string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("bc_app_key")))
+	mac.Write([]byte(uuid + "|" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPayslipLink Check the signature of a shared payslip link and that it has not expired ...
+func VerifyPayslipLink(uuid string, expires int64, signature string, now time.Time) bool {
+	if now.Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(SignPayslipLink(uuid, expires)), []byte(signature))
+}
+
+// PayslipLayoutPath The layout payslip PDFs are drawn with, bc_payslip_layout or
+// the default layout ...
+func PayslipLayoutPath() string {
+	if path := os.Getenv("bc_payslip_layout"); path != "" {
+		return path
+	}
+	return "layouts/payslip.json"
+}
+
+// organisationValues The branding of the organisation a payslip is issued by, as
+// {org_name}, {org_address}, {brand_color} and so on ...
+func organisationValues(values map[string]string, organisation models.Organisation) {
+	values["org_name"] = organisation.Title()
+	values["org_short_name"] = organisation.Name
+	var address []string
+	for _, line := range strings.Split(organisation.Address, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			address = append(address, line)
+		}
+	}
+	values["org_address"] = strings.Join(address, ", ")
+	values["org_state"] = organisation.State
+	values["org_pan"] = organisation.PAN
+	values["org_tan"] = organisation.TAN
+	var taxIDs []string
+	if organisation.PAN != "" {
+		taxIDs = append(taxIDs, "PAN: "+organisation.PAN)
+	}
+	if organisation.TAN != "" {
+		taxIDs = append(taxIDs, "TAN: "+organisation.TAN)
+	}
+	values["org_tax_ids"] = strings.Join(taxIDs, "   ")
+	values["brand_color"] = organisation.BrandColor
+	if values["brand_color"] == "" {
+		values["brand_color"] = models.DefaultBrandColor
+	}
+	values["accent_color"] = organisation.AccentColor
+	if values["accent_color"] == "" {
+		values["accent_color"] = values["brand_color"]
+	}
+}
+
+// PayslipData The values, lists and images of a payslip issued by an organisation
+// that layouts bind to. Every component is also available by its code, as
+// {earning:BASIC} or {deduction:PT}, and the organisation logo as {logo}. Arrear
+// lines are marked (*), their codes are {arrear:BASIC} and their total {arrears}.
+// Amounts are written in the currency of the payslip, {currency}, and a foreign
+// currency payslip also has its {exchange_rate} and net pay in rupees as {net_base} ...
+func PayslipData(payslip *models.Payslip, organisation models.Organisation) layout.Data {
+	payroll.Backfill(payslip)
+	formatAmount := func(amount money.Amount) string {
+		return currency.Format(amount, payslip.Currency)
+	}
+	data := layout.Data{
+		Values: map[string]string{
+			"period":      payslip.Month.Format("Jan 2006"),
+			"pay_date":    payslip.Day.Format("02 Jan 2006"),
+			"name":        payslip.Name,
+			"position":    payslip.Position,
+			"employee_no": payslip.EmployeeNo,
+			"department":  payslip.Department,
+			"pan":         payslip.PAN,
+			"uan":         payslip.UAN,
+			"account_no":  payslip.AccountNo,
+			"ifsc_code":   payslip.IFSCCode,
+			"gross":       formatAmount(payroll.Total(payslip.Earnings)),
+			"deductions":  formatAmount(payroll.Total(payslip.Deductions)),
+			"net":         formatAmount(payslip.AmountReceivedBank),
+			"currency":    payslip.CurrencyCode(),
+		},
+		Lists:  map[string][]layout.Line{},
+		Images: map[string][]byte{},
+	}
+	organisationValues(data.Values, organisation)
+	if logo, err := base64.StdEncoding.DecodeString(organisation.Logo); err == nil && len(logo) > 0 {
+		data.Images["logo"] = logo
+	}
+	if !payslip.DateOfJoining.IsZero() {
+		data.Values["date_of_joining"] = payslip.DateOfJoining.Format("02 Jan 2006")
+	}
+	if payslip.WorkingDays > 0 {
+		data.Values["days_worked"] = strconv.FormatFloat(payslip.PaidDays, 'f', -1, 64) + " of " + strconv.FormatFloat(payslip.WorkingDays, 'f', -1, 64)
+	}
+	if payslip.LOPDays > 0 {
+		data.Values["lop_days"] = strconv.FormatFloat(payslip.LOPDays, 'f', -1, 64)
+	}
+	if currency.IsForeign(payslip.Currency) && payslip.ExchangeRate > 0 {
+		data.Values["exchange_rate"] = "1 " + payslip.CurrencyCode() + " = " + strconv.FormatFloat(payslip.ExchangeRate, 'f', 4, 64) + " " + currency.Base
+		data.Values["net_base"] = currency.Base + " " + currency.Format(currency.ToBase(payslip.AmountReceivedBank, payslip.ExchangeRate), currency.Base)
+	}
+	if payslip.VerificationCode != "" {
+		data.Values["verify_url"] = VerifyURL(payslip.VerificationCode)
+		data.Values["verify_code"] = FormatVerificationCode(payslip.VerificationCode)
+	}
+	var arrears money.Amount
+	for _, earning := range payslip.Earnings {
+		if earning.Arrear {
+			arrears += earning.Amount
+			data.Values["arrear:"+earning.Code] = formatAmount(earning.Amount)
+			data.Lists["earnings"] = append(data.Lists["earnings"], layout.Line{Label: earning.Name + " (*)", Value: formatAmount(earning.Amount)})
+			continue
+		}
+		data.Values["earning:"+earning.Code] = formatAmount(earning.Amount)
+		data.Lists["earnings"] = append(data.Lists["earnings"], layout.Line{Label: earning.Name, Value: formatAmount(earning.Amount)})
+	}
+	if payroll.HasArrears(payslip.Earnings) {
+		data.Values["arrears"] = formatAmount(arrears)
+	}
+	for _, deduction := range payslip.Deductions {
+		data.Values["deduction:"+deduction.Code] = formatAmount(deduction.Amount)
+		data.Lists["deductions"] = append(data.Lists["deductions"], layout.Line{Label: deduction.Name, Value: formatAmount(deduction.Amount)})
+	}
+	// paid by the employer on top of the gross, printed but not deducted
+	for _, contribution := range payslip.EmployerContributions {
+		data.Values["employer:"+contribution.Code] = formatAmount(contribution.Amount)
+		data.Lists["employer_contributions"] = append(data.Lists["employer_contributions"], layout.Line{Label: contribution.Name, Value: formatAmount(contribution.Amount)})
+	}
+	if len(payslip.EmployerContributions) > 0 {
+		data.Values["employer_contributions"] = formatAmount(payroll.Total(payslip.EmployerContributions))
+	}
+	return data
+}
+
+// GeneratePayslipPDF generate PDF for payslip with the payslip layout and the
+// branding of the organisation, encrypted with a password derived from the
+// payslip when the organisation protects its PDFs ...
+func GeneratePayslipPDF(payslip *models.Payslip, organisation models.Organisation) error {
+	pdf, err := DrawPayslipPDF(payslip, organisation)
+	if err != nil {
+		return err
+	}
+	return WritePayslipPDF(payslip.UUID, pdf)
+}
+
+// DrawPayslipPDF Draw the PDF of a payslip like GeneratePayslipPDF, in memory so
+// nothing reaches the disk before it is signed ...
+func DrawPayslipPDF(payslip *models.Payslip, organisation models.Organisation) ([]byte, error) {
+	protection := organisation.PDFProtection
+	payslipLayout, err := layout.Load(PayslipLayoutPath())
+	if err != nil {
+		return nil, err
+	}
+	renderer := layout.PDF{}
+	if protection.Enabled {
+		if renderer.UserPassword, err = PayslipPassword(protection.PasswordRule, payslip); err != nil {
+			return nil, err
+		}
+		renderer.OwnerPassword = pdfOwnerPassword()
+	}
+	return drawPayslip(renderer, payslipLayout, payslip, organisation)
+}
+
+// RenderPayslip Draw a payslip of an organisation with a renderer to its PDF path ...
+func RenderPayslip(renderer layout.Renderer, payslipLayout *layout.Layout, payslip *models.Payslip, organisation models.Organisation) error {
+	pdf, err := drawPayslip(renderer, payslipLayout, payslip, organisation)
+	if err != nil {
+		return err
+	}
+	return WritePayslipPDF(payslip.UUID, pdf)
+}
+
+// drawPayslip Draw a payslip of an organisation with a renderer in memory ...
+func drawPayslip(renderer layout.Renderer, payslipLayout *layout.Layout, payslip *models.Payslip, organisation models.Organisation) ([]byte, error) {
+	var pdf bytes.Buffer
+	if err := renderer.Render(&pdf, payslipLayout, PayslipData(payslip, organisation)); err != nil {
+		return nil, err
+	}
+	return pdf.Bytes(), nil
+}
+
+// WritePayslipPDF Put a payslip PDF at its path. It is written to a temporary file
+// first and renamed into place, a PDF on disk is never half written ...
+func WritePayslipPDF(uuid string, pdf []byte) error {
+	path := PayslipPDFPath(uuid)
+	file, err := ioutil.TempFile(filepath.Dir(path), uuid+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(pdf); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err = os.Rename(file.Name(), path); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"time"
+
+	"bcpayslip/models"
+	"bcpayslip/money"
+)
+
+// PayslipForm The fields an employee fills in on the payslip form. Forms are
+// decoded into this rather than into a payslip, so its lines, amounts, status and
+// signature can only be filled on the server ...
+type PayslipForm struct {
+	Name              string
+	EmployeeNo        string
+	Position          string
+	Day               time.Time
+	Month             time.Time
+	GrossAnnualSalary money.Amount
+	AccountNo         string
+	IFSCCode          string
+	State             string
+	WorkingDays       float64
+	LOPDays           float64
+	Declaration       models.TaxDeclaration
+}
+
+// Apply Copy the entered fields to a payslip ...
+func (form PayslipForm) Apply(payslip *models.Payslip) {
+	payslip.Name = form.Name
+	payslip.EmployeeNo = form.EmployeeNo
+	payslip.Position = form.Position
+	payslip.Day = form.Day
+	payslip.Month = form.Month
+	payslip.GrossAnnualSalary = form.GrossAnnualSalary
+	payslip.AccountNo = form.AccountNo
+	payslip.IFSCCode = form.IFSCCode
+	payslip.State = form.State
+	payslip.WorkingDays = form.WorkingDays
+	payslip.LOPDays = form.LOPDays
+	payslip.Declaration = form.Declaration
+}
@@ -0,0 +1,173 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"bcpayslip/models"
+)
+
+// passwordField A value of the payslip a PDF password can be made of ...
+type passwordField struct {
+	Describe string
+	Unit     string
+	Value    func(payslip *models.Payslip) string
+	Missing  string
+}
+
+// passwordFields Placeholders of a password rule, {PAN} is the whole value and
+// {PAN:4} its first four characters ...
+var passwordFields = map[string]passwordField{
+	"PAN": {
+		Describe: "your PAN in capitals",
+		Unit:     "characters",
+		Value:    func(payslip *models.Payslip) string { return strings.ToUpper(payslip.PAN) },
+		Missing:  "PAN",
+	},
+	"DOB": {
+		Describe: "your date of birth as DDMMYYYY",
+		Unit:     "digits",
+		Value: func(payslip *models.Payslip) string {
+			if payslip.DateOfBirth.IsZero() {
+				return ""
+			}
+			return payslip.DateOfBirth.Format("02012006")
+		},
+		Missing: "date of birth",
+	},
+	"EMPNO": {
+		Describe: "your employee number",
+		Unit:     "characters",
+		Value:    func(payslip *models.Payslip) string { return payslip.EmployeeNo },
+		Missing:  "employee number",
+	},
+	"NAME": {
+		Describe: "your first name in capitals",
+		Unit:     "letters",
+		Value: func(payslip *models.Payslip) string {
+			first := strings.Fields(payslip.Name)
+			if len(first) == 0 {
+				return ""
+			}
+			return strings.ToUpper(strings.TrimFunc(first[0], func(r rune) bool { return !unicode.IsLetter(r) }))
+		},
+		Missing: "name",
+	},
+}
+
+// passwordPart A literal or a placeholder of a password rule ...
+type passwordPart struct {
+	Literal string
+	Field   string
+	Length  int
+}
+
+// parsePasswordRule ...
+func parsePasswordRule(rule string) ([]passwordPart, error) {
+	var parts []passwordPart
+	placeholders := 0
+	for rule != "" {
+		start := strings.Index(rule, "{")
+		if start < 0 {
+			parts = append(parts, passwordPart{Literal: rule})
+			break
+		}
+		if start > 0 {
+			parts = append(parts, passwordPart{Literal: rule[:start]})
+		}
+		end := strings.Index(rule, "}")
+		if end < start {
+			return nil, errors.New("a { in the password rule is not closed")
+		}
+		name := strings.ToUpper(strings.TrimSpace(rule[start+1 : end]))
+		part := passwordPart{Field: name}
+		if i := strings.Index(name, ":"); i >= 0 {
+			length, err := strconv.Atoi(name[i+1:])
+			if err != nil || length < 1 {
+				return nil, fmt.Errorf("{%s} needs a number of characters after the colon", name)
+			}
+			part.Field, part.Length = name[:i], length
+		}
+		if _, ok := passwordFields[part.Field]; !ok {
+			return nil, fmt.Errorf("{%s} is not a placeholder, use PAN, DOB, EMPNO or NAME", part.Field)
+		}
+		parts = append(parts, part)
+		placeholders++
+		rule = rule[end+1:]
+	}
+	if placeholders == 0 {
+		return nil, errors.New("the password rule needs a placeholder so every employee gets their own password")
+	}
+	return parts, nil
+}
+
+// ValidatePasswordRule Check a PDF password rule before it is saved ...
+func ValidatePasswordRule(rule string) error {
+	_, err := parsePasswordRule(rule)
+	return err
+}
+
+// PasswordHint How an employee works out the password of their payslip PDF ...
+func PasswordHint(rule string) string {
+	parts, err := parsePasswordRule(rule)
+	if err != nil {
+		return ""
+	}
+	var hints []string
+	for _, part := range parts {
+		if part.Literal != "" {
+			hints = append(hints, `"`+part.Literal+`"`)
+			continue
+		}
+		field := passwordFields[part.Field]
+		if part.Length > 0 {
+			hints = append(hints, fmt.Sprintf("the first %d %s of %s", part.Length, field.Unit, field.Describe))
+		} else {
+			hints = append(hints, field.Describe)
+		}
+	}
+	return strings.Join(hints, " followed by ")
+}
+
+// PayslipPassword Password of a payslip PDF under a rule, an error tells which
+// detail of the employee is missing ...
+func PayslipPassword(rule string, payslip *models.Payslip) (string, error) {
+	parts, err := parsePasswordRule(rule)
+	if err != nil {
+		return "", err
+	}
+	var password strings.Builder
+	for _, part := range parts {
+		if part.Literal != "" {
+			password.WriteString(part.Literal)
+			continue
+		}
+		field := passwordFields[part.Field]
+		value := field.Value(payslip)
+		if value == "" {
+			return "", fmt.Errorf("the %s of %s is needed for the PDF password", field.Missing, payslip.Name)
+		}
+		if part.Length > 0 && part.Length < len(value) {
+			value = value[:part.Length]
+		}
+		password.WriteString(value)
+	}
+	return password.String(), nil
+}
+
+// pdfOwnerPassword Password that unlocks editing of protected PDFs, bc_pdf_owner_password
+// or a random one nobody knows ...
+func pdfOwnerPassword() string {
+	if password := os.Getenv("bc_pdf_owner_password"); password != "" {
+		return password
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
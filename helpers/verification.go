@@ -0,0 +1,125 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+
+	"bcpayslip/money"
+	"bcpayslip/urls"
+)
+
+// verificationAlphabet Crockford base32, without letters that are read as digits ...
+const verificationAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ErrNoSigningKey Payslips can not be signed without a key ...
+var ErrNoSigningKey = errors.New("bc_signing_key is not set, payslips can not be signed")
+
+// NewVerificationCode Random ten character code a payslip is verified by, an error
+// when the system has no randomness to give ...
+func NewVerificationCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := make([]byte, len(b))
+	for i := range b {
+		code[i] = verificationAlphabet[int(b[i])%len(verificationAlphabet)]
+	}
+	return string(code), nil
+}
+
+// NormalizeVerificationCode A code as it was typed in, with look-alike letters
+// read as digits and anything that can not be part of a code dropped ...
+func NormalizeVerificationCode(code string) string {
+	code = strings.NewReplacer("O", "0", "I", "1", "L", "1").Replace(strings.ToUpper(code))
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(verificationAlphabet, r) {
+			return r
+		}
+		return -1
+	}, code)
+}
+
+// FormatVerificationCode A code in groups of five that are easy to read out ...
+func FormatVerificationCode(code string) string {
+	if len(code) <= 5 {
+		return code
+	}
+	return code[:5] + "-" + code[5:]
+}
+
+// VerifyURL Public page a payslip is verified at ...
+func VerifyURL(code string) string {
+	return os.Getenv("bc_host") + strings.Replace(urls.VerifyPath, "{code}", code, 1)
+}
+
+// signingKey The ed25519 key payslips are signed with, bc_signing_key holds its
+// base64 seed. Development falls back to a key derived from bc_app_key ...
+func signingKey() (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(os.Getenv("bc_signing_key"))
+	if err == nil && len(seed) == ed25519.SeedSize {
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if os.Getenv("bc_signing_key") != "" {
+		return nil, errors.New("bc_signing_key is not a base64 ed25519 seed of 32 bytes")
+	}
+	if os.Getenv("bc_env") == "development" {
+		derived := sha256.Sum256([]byte("payslip signing|" + os.Getenv("bc_app_key")))
+		return ed25519.NewKeyFromSeed(derived[:]), nil
+	}
+	return nil, ErrNoSigningKey
+}
+
+// signingKeyID Short id of a public key, stored with signatures so a rotated key
+// is reported as such ...
+func signingKeyID(public ed25519.PublicKey) string {
+	sum := sha256.Sum256(public)
+	return hex.EncodeToString(sum[:8])
+}
+
+// SignPayslipPDF Detached signature of the bytes of a payslip PDF and the id of
+// the key that made it ...
+func SignPayslipPDF(pdf []byte) (string, string, error) {
+	key, err := signingKey()
+	if err != nil {
+		return "", "", err
+	}
+	signature := ed25519.Sign(key, pdf)
+	return signingKeyID(key.Public().(ed25519.PublicKey)), base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// VerifyPayslipPDF Check a PDF against the signature stored with its payslip, any
+// change to the file fails ...
+func VerifyPayslipPDF(pdf []byte, keyID string, signature string) error {
+	key, err := signingKey()
+	if err != nil {
+		return err
+	}
+	public := key.Public().(ed25519.PublicKey)
+	if keyID != signingKeyID(public) {
+		return errors.New("the payslip was signed with a key that is no longer in use")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(public, pdf, sig) {
+		return errors.New("the file does not match the signed payslip")
+	}
+	return nil
+}
+
+// NetPayHash Fingerprint of the net pay of a payslip, shown on the public
+// verification page instead of the amount. It is keyed with bc_app_key, net pays
+// are few enough to be guessed from an unkeyed hash and the printed code ...
+func NetPayHash(code string, netPay money.Amount) string {
+	mac := hmac.New(sha256.New, []byte("net pay|"+os.Getenv("bc_app_key")))
+	mac.Write([]byte(code + "|" + netPay.String()))
+	sum := mac.Sum(nil)
+	digest := strings.ToUpper(hex.EncodeToString(sum[:8]))
+	return digest[:4] + " " + digest[4:8] + " " + digest[8:12] + " " + digest[12:]
+}
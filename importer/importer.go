@@ -0,0 +1,198 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"bcpayslip/models"
+	"bcpayslip/money"
+	"bcpayslip/payroll"
+)
+
+// Columns of the import sheet, a header matches a column when it is one of its
+// names ignoring case, spaces and punctuation ...
+var columns = map[string][]string{
+	"employeeno":    {"employeeno", "empno", "employeenumber", "employeeid", "empid", "employeecode", "empcode"},
+	"gross":         {"gross", "grosssalary", "monthlygross", "grossmonthlysalary"},
+	"lopdays":       {"lopdays", "lop", "lossofpaydays", "lossofpay"},
+	"workingdays":   {"workingdays", "daysinmonth", "totaldays"},
+	"paiddays":      {"paiddays", "daysworked", "payabledays", "attendance"},
+	"bonus":         {"bonus"},
+	"reimbursement": {"reimbursement", "reimbursements"},
+	"bankcredit":    {"bankcredit", "netpay", "amountreceivedbank", "amountcredited"},
+}
+
+// ErrUnsupportedFile Only csv and xlsx spreadsheets can be imported ...
+var ErrUnsupportedFile = errors.New("upload a .csv or .xlsx file")
+
+// Read Rows of an uploaded spreadsheet, the format is picked by the file extension ...
+func Read(r io.Reader, fileName string) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		return reader.ReadAll()
+	case ".xlsx":
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return readXLSX(bytes.NewReader(data), int64(len(data)))
+	}
+	return nil, ErrUnsupportedFile
+}
+
+// normalise Lower case a header and drop everything but letters and digits ...
+func normalise(header string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(header) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// headerIndex Position of every known column in the header row ...
+func headerIndex(header []string) (map[string]int, error) {
+	index := make(map[string]int)
+	for i, cell := range header {
+		name := normalise(cell)
+		for column, names := range columns {
+			for _, alias := range names {
+				if name == alias {
+					index[column] = i
+				}
+			}
+		}
+	}
+	var missing []string
+	if _, ok := index["employeeno"]; !ok {
+		missing = append(missing, "Employee No")
+	}
+	if _, ok := index["gross"]; !ok {
+		missing = append(missing, "Gross")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("line 1: missing column %s", strings.Join(missing, ", "))
+	}
+	return index, nil
+}
+
+// parseNumber Parse a spreadsheet number, blanks are zero and digit grouping commas are allowed ...
+func parseNumber(value string) (float64, error) {
+	value = strings.Replace(strings.TrimSpace(value), ",", "", -1)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// ParseRows Validate the rows of a spreadsheet for a month, the first row is the
+// header, blank rows are skipped and every other row is returned with the problems
+// found in it. Lines are numbered as the spreadsheet shows them ...
+func ParseRows(records [][]string, month time.Time) ([]models.ImportRow, error) {
+	if len(records) == 0 {
+		return nil, errors.New("the file is empty")
+	}
+	index, err := headerIndex(records[0])
+	if err != nil {
+		return nil, err
+	}
+	days := float64(payroll.DaysIn(month))
+	seen := make(map[string]int)
+	var rows []models.ImportRow
+	for i, record := range records[1:] {
+		cell := func(column string) string {
+			position, ok := index[column]
+			if !ok || position >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[position])
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		row := models.ImportRow{Line: i + 2, EmployeeNo: cell("employeeno")}
+		if row.EmployeeNo == "" {
+			row.Errors = append(row.Errors, "Employee No is missing")
+		} else if line, ok := seen[row.EmployeeNo]; ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("Employee No %s is also on line %d", row.EmployeeNo, line))
+		} else {
+			seen[row.EmployeeNo] = row.Line
+		}
+		amounts := []struct {
+			column string
+			label  string
+			value  *money.Amount
+		}{
+			{"gross", "Gross", &row.Input.Gross},
+			{"bonus", "Bonus", &row.Input.Bonus},
+			{"reimbursement", "Reimbursement", &row.Input.Reimbursement},
+			{"bankcredit", "Bank Credit", &row.Input.BankCredit},
+		}
+		for _, amount := range amounts {
+			value, err := money.Parse(cell(amount.column))
+			switch {
+			case err != nil:
+				row.Errors = append(row.Errors, fmt.Sprintf("%s %q is not a number", amount.label, cell(amount.column)))
+			case value < 0:
+				row.Errors = append(row.Errors, amount.label+" can not be negative")
+			default:
+				*amount.value = value
+			}
+		}
+		dayCounts := []struct {
+			column string
+			label  string
+			value  *float64
+		}{
+			{"lopdays", "LOP Days", &row.Input.LOPDays},
+			{"workingdays", "Working Days", &row.Input.WorkingDays},
+			{"paiddays", "Paid Days", &row.Input.PaidDays},
+		}
+		for _, count := range dayCounts {
+			value, err := parseNumber(cell(count.column))
+			switch {
+			case err != nil:
+				row.Errors = append(row.Errors, fmt.Sprintf("%s %q is not a number", count.label, cell(count.column)))
+			case value < 0:
+				row.Errors = append(row.Errors, count.label+" can not be negative")
+			default:
+				*count.value = value
+			}
+		}
+		if row.Input.Gross == 0 && cell("gross") == "" {
+			row.Errors = append(row.Errors, "Gross is missing")
+		}
+		working := days
+		if row.Input.WorkingDays > days {
+			row.Errors = append(row.Errors, fmt.Sprintf("Working Days can not be more than the %d days of %s", int(days), month.Format("Jan 2006")))
+		} else if row.Input.WorkingDays > 0 {
+			working = row.Input.WorkingDays
+		}
+		if row.Input.LOPDays > working {
+			row.Errors = append(row.Errors, fmt.Sprintf("LOP Days can not be more than the %v working days", working))
+		}
+		if row.Input.PaidDays > working {
+			row.Errors = append(row.Errors, fmt.Sprintf("Paid Days can not be more than the %v working days", working))
+		}
+		if row.Input.PaidDays > 0 && row.Input.PaidDays+row.Input.LOPDays > working {
+			row.Errors = append(row.Errors, "Paid Days and LOP Days add up to more than the working days")
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the file has no data rows")
+	}
+	return rows, nil
+}
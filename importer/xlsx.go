@@ -0,0 +1,159 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// The parts of an xlsx workbook needed to read the cell values of its first sheet ...
+type (
+	xlsxWorkbook struct {
+		Sheets []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	xlsxRelationships struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	xlsxText struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	}
+	xlsxSharedStrings struct {
+		Items []xlsxText `xml:"si"`
+	}
+	xlsxSheet struct {
+		Rows []struct {
+			Number int `xml:"r,attr"`
+			Cells  []struct {
+				Ref    string   `xml:"r,attr"`
+				Type   string   `xml:"t,attr"`
+				Value  string   `xml:"v"`
+				Inline xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+)
+
+// String Text of a shared or inline string, rich text is made of runs ...
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+// decodePart Decode an xml part of the workbook, missing optional parts are left empty ...
+func decodePart(files map[string]*zip.File, name string, v interface{}, required bool) error {
+	file, ok := files[name]
+	if !ok {
+		if required {
+			return errors.New("not a valid xlsx file, " + name + " is missing")
+		}
+		return nil
+	}
+	r, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// firstSheet Path of the first worksheet in the workbook ...
+func firstSheet(files map[string]*zip.File) string {
+	var workbook xlsxWorkbook
+	var rels xlsxRelationships
+	if decodePart(files, "xl/workbook.xml", &workbook, true) != nil || len(workbook.Sheets) == 0 {
+		return "xl/worksheets/sheet1.xml"
+	}
+	if decodePart(files, "xl/_rels/workbook.xml.rels", &rels, true) != nil {
+		return "xl/worksheets/sheet1.xml"
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[0].RelID {
+			if strings.HasPrefix(rel.Target, "/") {
+				return strings.TrimPrefix(rel.Target, "/")
+			}
+			return path.Join("xl", rel.Target)
+		}
+	}
+	return "xl/worksheets/sheet1.xml"
+}
+
+// columnIndex Zero based column of a cell reference like "C12" ...
+func columnIndex(ref string) int {
+	column := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A'+1)
+	}
+	return column - 1
+}
+
+// readXLSX Cell values of the first sheet of an xlsx workbook as text, gaps left by
+// empty rows and cells are filled with blanks ...
+func readXLSX(r io.ReaderAt, size int64) ([][]string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("not a valid xlsx file")
+	}
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	var shared xlsxSharedStrings
+	if err = decodePart(files, "xl/sharedStrings.xml", &shared, false); err != nil {
+		return nil, err
+	}
+	var sheet xlsxSheet
+	if err = decodePart(files, firstSheet(files), &sheet, true); err != nil {
+		return nil, err
+	}
+	var records [][]string
+	for _, row := range sheet.Rows {
+		for row.Number > len(records)+1 {
+			records = append(records, nil)
+		}
+		var record []string
+		for _, cell := range row.Cells {
+			column := len(record)
+			if cell.Ref != "" {
+				column = columnIndex(cell.Ref)
+			}
+			for len(record) <= column {
+				record = append(record, "")
+			}
+			switch cell.Type {
+			case "s":
+				i, err := strconv.Atoi(cell.Value)
+				if err != nil || i < 0 || i >= len(shared.Items) {
+					return nil, errors.New("not a valid xlsx file, bad shared string in " + cell.Ref)
+				}
+				record[column] = shared.Items[i].String()
+			case "inlineStr":
+				record[column] = cell.Inline.String()
+			default:
+				record[column] = cell.Value
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
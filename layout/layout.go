@@ -0,0 +1,267 @@
+// Package layout describes payslip documents as data so their look can change
+// without code changes. A layout is a JSON file of sections stacked from the top
+// of the page, each a box of positioned items, a grid of labelled fields or side
+// by side panels of amounts. Texts bind to the data of a payslip with
+// placeholders such as {name}, {net} or {earning:BONUS}, see Data. Colours can
+// be placeholders too, so one layout prints the branding of every organisation.
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Section types ...
+const (
+	SectionItems  = "items"
+	SectionFields = "fields"
+	SectionPanels = "panels"
+)
+
+type (
+	// Layout A payslip document ...
+	Layout struct {
+		Font     string    `json:"font"`
+		FontSize float64   `json:"fontSize"`
+		Left     float64   `json:"left"`
+		Top      float64   `json:"top"`
+		Width    float64   `json:"width"`
+		Sections []Section `json:"sections"`
+	}
+	// Section A band of the page, as wide as the layout, optionally boxed ...
+	Section struct {
+		Type        string  `json:"type"`
+		Box         bool    `json:"box"`
+		Height      float64 `json:"height"`
+		MinHeight   float64 `json:"minHeight"`
+		Items       []Item  `json:"items"`
+		Fields      []Field `json:"fields"`
+		Columns     int     `json:"columns"`
+		ColumnWidth float64 `json:"columnWidth"`
+		LabelWidth  float64 `json:"labelWidth"`
+		RowHeight   float64 `json:"rowHeight"`
+		MinRows     int     `json:"minRows"`
+		Panels      []Panel `json:"panels"`
+		If          string  `json:"if"`
+	}
+	// Item A text, image or QR code at a position inside its section. An image is
+	// a file or an image of the data such as {logo}. Items with If are only drawn
+	// when that value or image is set, items with Unless when it is not ...
+	Item struct {
+		Text   string  `json:"text"`
+		Image  string  `json:"image"`
+		QR     string  `json:"qr"`
+		X      float64 `json:"x"`
+		Y      float64 `json:"y"`
+		W      float64 `json:"w"`
+		H      float64 `json:"h"`
+		Style  string  `json:"style"`
+		Size   float64 `json:"size"`
+		Color  string  `json:"color"`
+		Align  string  `json:"align"`
+		If     string  `json:"if"`
+		Unless string  `json:"unless"`
+	}
+	// Field A label and the value it binds to, optional fields are left out when
+	// their value is empty ...
+	Field struct {
+		Label    string `json:"label"`
+		Value    string `json:"value"`
+		Optional bool   `json:"optional"`
+	}
+	// Panel A titled column of a panels section, listing either a list of the data
+	// such as earnings or its own fields ...
+	Panel struct {
+		Title      string  `json:"title"`
+		Header     string  `json:"header"`
+		Width      float64 `json:"width"`
+		Padding    float64 `json:"padding"`
+		LabelWidth float64 `json:"labelWidth"`
+		ValueWidth float64 `json:"valueWidth"`
+		List       string  `json:"list"`
+		Fields     []Field `json:"fields"`
+	}
+	// Line A row of a list, such as one earning ...
+	Line struct {
+		Label string
+		Value string
+	}
+	// Data The values, lists and images of one payslip that placeholders bind to ...
+	Data struct {
+		Values map[string]string
+		Lists  map[string][]Line
+		Images map[string][]byte
+	}
+	// Renderer Draws a layout filled with data into a document ...
+	Renderer interface {
+		Render(w io.Writer, layout *Layout, data Data) error
+	}
+)
+
+// Load Read and check a layout file ...
+func Load(path string) (*Layout, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// Parse Read and check a layout, missing sizes get the defaults of an A4 page ...
+func Parse(r io.Reader) (*Layout, error) {
+	var layout Layout
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&layout); err != nil {
+		return nil, fmt.Errorf("layout: %v", err)
+	}
+	if layout.Font == "" {
+		layout.Font = "Arial"
+	}
+	if layout.FontSize == 0 {
+		layout.FontSize = 10
+	}
+	if layout.Left == 0 {
+		layout.Left = 10
+	}
+	if layout.Top == 0 {
+		layout.Top = 10
+	}
+	if layout.Width == 0 {
+		layout.Width = 190
+	}
+	for i := range layout.Sections {
+		section := &layout.Sections[i]
+		if section.RowHeight == 0 {
+			section.RowHeight = 10
+		}
+		switch section.Type {
+		case SectionItems:
+			for _, item := range section.Items {
+				if item.Text == "" && item.Image == "" && item.QR == "" {
+					return nil, fmt.Errorf("layout: section %d has an item without text, image or qr", i+1)
+				}
+			}
+		case SectionFields:
+			if section.Columns == 0 {
+				section.Columns = 2
+			}
+			if section.ColumnWidth == 0 {
+				section.ColumnWidth = (layout.Width - 20) / float64(section.Columns)
+			}
+			if section.LabelWidth == 0 {
+				section.LabelWidth = section.ColumnWidth / 2
+			}
+		case SectionPanels:
+			if len(section.Panels) == 0 {
+				return nil, fmt.Errorf("layout: section %d has no panels", i+1)
+			}
+			for j := range section.Panels {
+				panel := &section.Panels[j]
+				if panel.Width == 0 {
+					panel.Width = layout.Width / float64(len(section.Panels))
+				}
+				if panel.LabelWidth == 0 {
+					panel.LabelWidth = (panel.Width - panel.Padding) * 2 / 3
+				}
+				if panel.ValueWidth == 0 {
+					panel.ValueWidth = panel.Width - panel.Padding - panel.LabelWidth
+				}
+			}
+		default:
+			return nil, fmt.Errorf("layout: section %d has unknown type %q", i+1, section.Type)
+		}
+	}
+	return &layout, nil
+}
+
+// Bind Replace the placeholders in a text with values of the data, unknown
+// placeholders become empty ...
+func (d Data) Bind(text string) string {
+	var out strings.Builder
+	for {
+		start := strings.Index(text, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(text[start:], "}")
+		if end < 0 {
+			break
+		}
+		out.WriteString(text[:start])
+		out.WriteString(d.Values[text[start+1:start+end]])
+		text = text[start+end+1:]
+	}
+	out.WriteString(text)
+	return out.String()
+}
+
+// has Whether the data has a value or an image by that name ...
+func (d Data) has(name string) bool {
+	return d.Values[name] != "" || len(d.Images[name]) > 0
+}
+
+// visible Whether an item is drawn for the data ...
+func (d Data) visible(item Item) bool {
+	if item.If != "" && !d.has(item.If) {
+		return false
+	}
+	return item.Unless == "" || !d.has(item.Unless)
+}
+
+// image The image of the data an image placeholder such as {logo} names ...
+func (d Data) image(text string) ([]byte, string, bool) {
+	if !strings.HasPrefix(text, "{") || !strings.HasSuffix(text, "}") {
+		return nil, "", false
+	}
+	name := text[1 : len(text)-1]
+	image, ok := d.Images[name]
+	return image, name, ok && len(image) > 0
+}
+
+// fields Labels and values of the fields that are shown ...
+func (d Data) fields(fields []Field) []Line {
+	var lines []Line
+	for _, field := range fields {
+		value := d.Bind(field.Value)
+		if field.Optional && strings.TrimSpace(value) == "" {
+			continue
+		}
+		lines = append(lines, Line{Label: d.Bind(field.Label), Value: value})
+	}
+	return lines
+}
+
+// rows Lines of a panel ...
+func (d Data) rows(panel Panel) []Line {
+	if panel.List != "" {
+		return d.Lists[panel.List]
+	}
+	return d.fields(panel.Fields)
+}
+
+// HeightFor Height of a section filled with data ...
+func (s Section) HeightFor(d Data) float64 {
+	height := s.Height
+	switch s.Type {
+	case SectionFields:
+		rows := (len(d.fields(s.Fields)) + s.Columns - 1) / s.Columns
+		height = float64(rows) * s.RowHeight
+	case SectionPanels:
+		rows := s.MinRows
+		for _, panel := range s.Panels {
+			if n := len(d.rows(panel)); n > rows {
+				rows = n
+			}
+		}
+		height = float64(rows+1) * s.RowHeight
+	}
+	if height < s.MinHeight {
+		height = s.MinHeight
+	}
+	return height
+}
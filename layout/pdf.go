@@ -0,0 +1,169 @@
+package layout
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"bcpayslip/qrcode"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// PDF The default renderer, an A4 portrait PDF drawn with gofpdf. When
+// UserPassword is set the PDF is encrypted, readers can print and copy from it
+// but only the owner password allows changes ...
+type PDF struct {
+	UserPassword  string
+	OwnerPassword string
+}
+
+// Render ...
+func (r PDF) Render(w io.Writer, layout *Layout, data Data) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	if r.UserPassword != "" {
+		pdf.SetProtection(gofpdf.CnProtectPrint|gofpdf.CnProtectCopy, r.UserPassword, r.OwnerPassword)
+	}
+	pdf.AddPage()
+	y := layout.Top
+	for _, section := range layout.Sections {
+		if section.If != "" && data.Values[section.If] == "" {
+			continue
+		}
+		height := section.HeightFor(data)
+		pdf.SetFont(layout.Font, "", layout.FontSize)
+		pdf.SetTextColor(0, 0, 0)
+		if section.Box {
+			pdf.Rect(layout.Left, y, layout.Width, height, "D")
+		}
+		switch section.Type {
+		case SectionItems:
+			drawItems(pdf, layout, section, data, y)
+		case SectionFields:
+			drawFields(pdf, layout, section, data, y)
+		case SectionPanels:
+			drawPanels(pdf, layout, section, data, y, height)
+		}
+		y += height
+	}
+	return pdf.Output(w)
+}
+
+// setColor Text colour from #rrggbb ...
+func setColor(pdf *gofpdf.Fpdf, color string) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+	if color == "" || err != nil {
+		pdf.SetTextColor(0, 0, 0)
+		return
+	}
+	pdf.SetTextColor(int(value>>16&0xFF), int(value>>8&0xFF), int(value&0xFF))
+}
+
+// drawItems ...
+func drawItems(pdf *gofpdf.Fpdf, layout *Layout, section Section, data Data, top float64) {
+	for _, item := range section.Items {
+		if !data.visible(item) {
+			continue
+		}
+		x, y := layout.Left+item.X, top+item.Y
+		switch {
+		case item.QR != "":
+			drawQRCode(pdf, data.Bind(item.QR), x, y, item.W)
+		case item.Image != "":
+			drawImage(pdf, data, item.Image, x, y, item.W, item.H)
+		default:
+			size := item.Size
+			if size == 0 {
+				size = layout.FontSize
+			}
+			pdf.SetFont(layout.Font, item.Style, size)
+			setColor(pdf, data.Bind(item.Color))
+			pdf.SetXY(x, y)
+			pdf.CellFormat(item.W, item.H, data.Bind(item.Text), "", 0, item.Align, false, 0, "")
+		}
+	}
+	pdf.SetFont(layout.Font, "", layout.FontSize)
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// drawImage Draw an image of the data or an image file, a missing or unreadable
+// image is left out. Width or height 0 keeps the aspect ratio ...
+func drawImage(pdf *gofpdf.Fpdf, data Data, image string, x float64, y float64, w float64, h float64) {
+	options := gofpdf.ImageOptions{ReadDpi: true}
+	name := data.Bind(image)
+	if b, key, ok := data.image(image); ok {
+		switch http.DetectContentType(b) {
+		case "image/png":
+			options.ImageType = "PNG"
+		case "image/jpeg":
+			options.ImageType = "JPG"
+		default:
+			return
+		}
+		name = "data:" + key
+		pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(b))
+	} else if _, err := os.Stat(name); err != nil {
+		return
+	}
+	if pdf.Err() {
+		// a broken image must not fail the whole payslip
+		pdf.ClearError()
+		return
+	}
+	pdf.ImageOptions(name, x, y, w, h, false, options, 0, "")
+}
+
+// drawFields Labels in bold and their values, filling the columns row by row ...
+func drawFields(pdf *gofpdf.Fpdf, layout *Layout, section Section, data Data, top float64) {
+	for i, field := range data.fields(section.Fields) {
+		pdf.SetXY(layout.Left+10+float64(i%section.Columns)*section.ColumnWidth, top+float64(i/section.Columns)*section.RowHeight)
+		pdf.SetFont(layout.Font, "B", layout.FontSize)
+		pdf.Cell(section.LabelWidth, section.RowHeight, field.Label)
+		pdf.SetFont(layout.Font, "", layout.FontSize)
+		pdf.Cell(section.ColumnWidth-section.LabelWidth, section.RowHeight, field.Value)
+	}
+}
+
+// drawPanels Panels side by side, each a bold title row over its lines, with a
+// line between boxed panels ...
+func drawPanels(pdf *gofpdf.Fpdf, layout *Layout, section Section, data Data, top float64, height float64) {
+	x := layout.Left
+	for i, panel := range section.Panels {
+		if i > 0 && section.Box {
+			pdf.Line(x, top, x, top+height)
+		}
+		pdf.SetXY(x+panel.Padding, top)
+		pdf.SetFont(layout.Font, "B", layout.FontSize)
+		pdf.Cell(panel.LabelWidth, section.RowHeight, data.Bind(panel.Title))
+		pdf.Cell(panel.ValueWidth, section.RowHeight, data.Bind(panel.Header))
+		pdf.SetFont(layout.Font, "", layout.FontSize)
+		for j, line := range data.rows(panel) {
+			pdf.SetXY(x+panel.Padding, top+float64(j+1)*section.RowHeight)
+			pdf.Cell(panel.LabelWidth, section.RowHeight, line.Label)
+			pdf.Cell(panel.ValueWidth, section.RowHeight, line.Value)
+		}
+		x += panel.Width
+	}
+}
+
+// drawQRCode Draw a QR code of a text with its top left corner at x, y, a text
+// too long for a QR code is left out ...
+func drawQRCode(pdf *gofpdf.Fpdf, text string, x float64, y float64, size float64) {
+	code, err := qrcode.Encode([]byte(text))
+	if err != nil {
+		return
+	}
+	// four modules of quiet zone on every side
+	module := size / float64(code.Size+8)
+	pdf.SetFillColor(0, 0, 0)
+	for row := 0; row < code.Size; row++ {
+		for col := 0; col < code.Size; col++ {
+			if code.Dark(col, row) {
+				pdf.Rect(x+float64(col+4)*module, y+float64(row+4)*module, module, module, "F")
+			}
+		}
+	}
+}
@@ -1,12 +1,19 @@
 package middlewares
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
 	"net/http"
 
+	"bcpayslip/store"
+	"bcpayslip/templates"
 	"bcpayslip/urls"
 	"bcpayslip/utils"
 
 	"github.com/gorilla/context"
+	"github.com/urfave/negroni"
 )
 
 // GothLoginMiddleware Retreiving session, redirecting if no session found ...
@@ -16,17 +23,90 @@ func GothLoginMiddleware(res http.ResponseWriter, req *http.Request, next http.H
 		context.Set(req, "userid", session.Values["userid"])
 	} else {
 		http.Redirect(res, req, urls.LogoutPath, http.StatusSeeOther)
+		return
 	}
 	next(res, req)
 }
 
-// SetUserMiddleware Appending the user id to every request and redirecting accordinly if no profile found ...
+// SetUserMiddleware Appending the user id and the organisation picked in the
+// session to every request and redirecting accordinly if no profile found ...
 func SetUserMiddleware(res http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
 	session, _ := utils.GetValidSession(req)
 	if session.Values["userid"] != nil {
 		context.Set(req, "userid", session.Values["userid"])
+		if orgID, ok := session.Values["orgid"].(string); ok {
+			context.Set(req, "orgid", orgID)
+		}
 	} else {
 		http.Redirect(res, req, urls.LogoutPath, http.StatusSeeOther)
+		return
+	}
+	next(res, req)
+}
+
+// RequireRole Let the request through only if the logged in user has the role ...
+func RequireRole(role string) negroni.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+		userID, _ := context.Get(req, "userid").(string)
+		user, err := store.GetUser(userID)
+		if err != nil || !utils.HasRole(user, role) {
+			utils.RedirectWithMessage(res, req, urls.HomePath, "You do not have access to that page")
+			return
+		}
+		next(res, req)
+	}
+}
+
+// maxFormSize Largest request body a form can post, uploads included ...
+const maxFormSize = 32 << 20
+
+// csrfToken Token of the login session that every form has to post back, a new
+// session gets a new token ...
+func csrfToken(res http.ResponseWriter, req *http.Request) (string, error) {
+	session, _ := utils.GetValidSession(req)
+	if token, ok := session.Values["csrf"].(string); ok && token != "" {
+		return token, nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	session.Values["csrf"] = token
+	return token, session.Save(req, res)
+}
+
+// ValidCSRFToken Whether the request posts back the token in the X-CSRF-Token
+// header or the csrf_token form field, an empty token is never valid ...
+func ValidCSRFToken(req *http.Request, token string) bool {
+	sent := req.Header.Get("X-CSRF-Token")
+	if sent == "" {
+		sent = req.FormValue("csrf_token")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(sent), []byte(token)) == 1
+}
+
+// CSRFMiddleware Make the csrf token available to templates and refuse state
+// changing requests that do not post it back in csrf_token or the X-CSRF-Token header ...
+func CSRFMiddleware(res http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	token, err := csrfToken(res, req)
+	if err != nil {
+		log.Println(err)
+		http.Error(res, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	context.Set(req, "csrf", token)
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		next(res, req)
+		return
+	}
+	req.Body = http.MaxBytesReader(res, req.Body, maxFormSize)
+	if !ValidCSRFToken(req, token) {
+		res.WriteHeader(http.StatusForbidden)
+		data := map[string]interface{}{"back": req.Referer()}
+		utils.CustomTemplateExecute(res, req, templates.CSRFErrorTemplate, data)
+		return
 	}
 	next(res, req)
 }
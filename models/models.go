@@ -1,16 +1,22 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"bcpayslip/currency"
+	"bcpayslip/money"
+)
 
 type (
 	// User type represents the registered user. ...
 	User struct {
-		UserID      string `json:"userid"`
-		FirstName   string `json:"firstname"`
-		LastName    string `json:"lastname"`
-		Email       string `json:"email"`
-		AccessToken string `json:"token,omitempty"`
-		Avatar      string `json:"avatar"`
+		UserID      string   `json:"userid"`
+		FirstName   string   `json:"firstname"`
+		LastName    string   `json:"lastname"`
+		Email       string   `json:"email"`
+		AccessToken string   `json:"token,omitempty"`
+		Avatar      string   `json:"avatar"`
+		Roles       []string `json:"roles"`
 	}
 	// Message Flash message Struct ...
 	Message struct {
@@ -23,21 +29,462 @@ type (
 	}
 	// Payslip ...
 	Payslip struct {
-		PayslipID          string    `json:"id"`
-		Name               string    `json:"name"`
-		Requestor          User      `bson:"requestor" json:"requestor"`
-		Approver           User      `bson:"approver" json:"approver"`
-		RequestedOn        time.Time `json:requestedon`
-		Day                time.Time `json:"day"`
-		Month              time.Time `json:"month"`
-		GrossAnnualSalary  float64   `json:"salary"`
-		AmountReceivedBank float64   `json:"amount"`
-		TDS                float64   `json:"tds"`
-		AccountNo          string    `json:"accountno"`
-		IFSCCode           string    `json:"ifsccode"`
-		Position           string    `json:"position"`
-		EmployeeNo         string    `json:"employeeno"`
-		Status             int       `json:"status"`
-		UUID               string    `json:"string"`
+		PayslipID             string         `json:"id"`
+		Name                  string         `json:"name"`
+		Requestor             User           `bson:"requestor" json:"requestor"`
+		Approver              User           `bson:"approver" json:"approver"`
+		RequestedOn           time.Time      `json:"requestedon"`
+		Day                   time.Time      `json:"day"`
+		Month                 time.Time      `json:"month"`
+		GrossAnnualSalary     money.Amount   `json:"salary"`
+		AmountReceivedBank    money.Amount   `json:"amount"`
+		TDS                   money.Amount   `json:"tds"`
+		AccountNo             string         `json:"accountno"`
+		IFSCCode              string         `json:"ifsccode"`
+		Position              string         `json:"position"`
+		EmployeeNo            string         `json:"employeeno"`
+		Grade                 string         `json:"grade"`
+		Department            string         `json:"department"`
+		DateOfJoining         time.Time      `json:"dateofjoining"`
+		DateOfLeaving         time.Time      `json:"dateofleaving"`
+		DateOfBirth           time.Time      `json:"dateofbirth"`
+		PAN                   string         `json:"pan"`
+		UAN                   string         `json:"uan"`
+		Earnings              []PayComponent `json:"earnings"`
+		Deductions            []PayComponent `json:"deductions"`
+		EmployerContributions []PayComponent `json:"employercontributions"`
+		State                 string         `json:"state"`
+		Declaration           TaxDeclaration `json:"declaration"`
+		Status                PayslipStatus  `json:"status"`
+		ActedOn               time.Time      `json:"actedon"`
+		IssuedOn              time.Time      `json:"issuedon"`
+		Remarks               string         `json:"remarks"`
+		UUID                  string         `json:"string"`
+		RunID                 string         `json:"runid"`
+		ImportID              string         `json:"importid"`
+		WorkingDays           float64        `json:"workingdays"`
+		PaidDays              float64        `json:"paiddays"`
+		LOPDays               float64        `json:"lopdays"`
+		VerificationCode      string         `json:"verificationcode"`
+		SignatureKey          string         `json:"signaturekey"`
+		Signature             string         `json:"signature"`
+		SignedOn              time.Time      `json:"signedon"`
+		OrgID                 string         `json:"orgid"`
+		Currency              string         `json:"currency"`
+		ExchangeRate          float64        `json:"exchangerate"`
+	}
+	// Employee Employment and bank details of a user, maintained by HR ...
+	Employee struct {
+		UserID        string         `json:"userid"`
+		EmployeeNo    string         `json:"employeeno"`
+		Name          string         `json:"name"`
+		Designation   string         `json:"designation"`
+		Department    string         `json:"department"`
+		Grade         string         `json:"grade"`
+		DateOfJoining time.Time      `json:"dateofjoining"`
+		DateOfLeaving time.Time      `json:"dateofleaving"`
+		DateOfBirth   time.Time      `json:"dateofbirth"`
+		PAN           string         `json:"pan"`
+		UAN           string         `json:"uan"`
+		AccountNo     string         `json:"accountno"`
+		IFSCCode      string         `json:"ifsccode"`
+		State         string         `json:"state"`
+		MonthlyGross  money.Amount   `json:"monthlygross"`
+		Currency      string         `json:"currency"`
+		Declaration   TaxDeclaration `json:"declaration"`
+		Active        bool           `json:"active"`
+		OrgID         string         `json:"orgid"`
+		UpdatedBy     string         `json:"updatedby"`
+		UpdatedOn     time.Time      `json:"updatedon"`
+	}
+	// PayComponent A computed earning or deduction line on a payslip, arrear lines
+	// are back pay for earlier months and are marked (*) on the payslip ...
+	PayComponent struct {
+		Name   string       `json:"name"`
+		Code   string       `json:"code"`
+		Amount money.Amount `json:"amount"`
+		Arrear bool         `json:"arrear"`
+	}
+	// TaxDeclaration Tax regime and annual investments declared by an employee ...
+	TaxDeclaration struct {
+		Regime            string  `json:"regime"`
+		Section80C        float64 `json:"section80c"`
+		Section80D        float64 `json:"section80d"`
+		Section80DParents float64 `json:"section80dparents"`
+		SeniorParents     bool    `json:"seniorparents"`
+		RentPaid          float64 `json:"rentpaid"`
+		MetroCity         bool    `json:"metrocity"`
+	}
+	// SalaryComponent One named part of a salary structure, its amount is a
+	// percentage of gross or of another component, a fixed amount, or a formula ...
+	SalaryComponent struct {
+		Name    string  `json:"name"`
+		Code    string  `json:"code"`
+		Type    string  `json:"type"`
+		Value   float64 `json:"value"`
+		Of      string  `json:"of"`
+		Formula string  `json:"formula"`
+	}
+	// SalaryStructure Ordered salary components that split the gross salary ...
+	SalaryStructure struct {
+		StructureID string            `json:"structureid"`
+		Name        string            `json:"name"`
+		Components  []SalaryComponent `json:"components"`
+		OrgID       string            `json:"orgid"`
+		UpdatedBy   string            `json:"updatedby"`
+		UpdatedOn   time.Time         `json:"updatedon"`
+	}
+	// StructureAssignment Links a salary structure to a user or to a grade ...
+	StructureAssignment struct {
+		Key         string `json:"key"`
+		UserID      string `json:"userid"`
+		Email       string `json:"email"`
+		Grade       string `json:"grade"`
+		StructureID string `json:"structureid"`
+		OrgID       string `json:"orgid"`
+	}
+	// PayslipAccess Audit record of a payslip PDF download ...
+	PayslipAccess struct {
+		AccessID   string    `json:"accessid"`
+		UUID       string    `json:"uuid"`
+		UserID     string    `json:"userid"`
+		Email      string    `json:"email"`
+		Via        string    `json:"via"`
+		RemoteAddr string    `json:"remoteaddr"`
+		AccessedOn time.Time `json:"accessedon"`
+	}
+	// UserSession Server side record of a login session, the cookie only carries its id ...
+	UserSession struct {
+		SessionID  string    `json:"sessionid"`
+		UserID     string    `json:"userid"`
+		Values     string    `json:"values"`
+		RemoteAddr string    `json:"remoteaddr"`
+		UserAgent  string    `json:"useragent"`
+		CreatedOn  time.Time `json:"createdon"`
+		LastSeenOn time.Time `json:"lastseenon"`
+		ExpiresOn  time.Time `json:"expireson"`
+	}
+	// LoginRejection Audit record of a login that was turned away ...
+	LoginRejection struct {
+		RejectionID string    `json:"rejectionid"`
+		Email       string    `json:"email"`
+		Provider    string    `json:"provider"`
+		Reason      string    `json:"reason"`
+		RemoteAddr  string    `json:"remoteaddr"`
+		AttemptedOn time.Time `json:"attemptedon"`
+	}
+	// PayrollRun Batch of payslips for every active employee of an organisation
+	// for a month, Key makes the period unique within the organisation ...
+	PayrollRun struct {
+		RunID       string           `json:"runid"`
+		Key         string           `json:"key"`
+		OrgID       string           `json:"orgid"`
+		Period      string           `json:"period"`
+		Month       time.Time        `json:"month"`
+		Status      PayrollRunStatus `json:"status"`
+		CreatedBy   string           `json:"createdby"`
+		CreatedOn   time.Time        `json:"createdon"`
+		PreviewedOn time.Time        `json:"previewedon"`
+		LockedBy    string           `json:"lockedby"`
+		LockedOn    time.Time        `json:"lockedon"`
+		PublishedBy string           `json:"publishedby"`
+		PublishedOn time.Time        `json:"publishedon"`
+		Results     []PayrollResult  `json:"results"`
 	}
+	// PayrollResult Outcome of a payroll run for one employee ...
+	PayrollResult struct {
+		UserID      string       `json:"userid"`
+		EmployeeNo  string       `json:"employeeno"`
+		Name        string       `json:"name"`
+		PayslipUUID string       `json:"payslipuuid"`
+		Gross       money.Amount `json:"gross"`
+		Deductions  money.Amount `json:"deductions"`
+		Net         money.Amount `json:"net"`
+		Currency    string       `json:"currency"`
+		Error       string       `json:"error"`
+	}
+	// MonthlyInput Figures of an employee for one month that are not part of the
+	// master record. Working and paid days are optional, see payroll.ApplyAttendance.
+	// Arrears are worked out from the arrears HR recorded and are never saved ...
+	MonthlyInput struct {
+		Gross         money.Amount   `json:"gross"`
+		WorkingDays   float64        `json:"workingdays"`
+		PaidDays      float64        `json:"paiddays"`
+		LOPDays       float64        `json:"lopdays"`
+		Bonus         money.Amount   `json:"bonus"`
+		Reimbursement money.Amount   `json:"reimbursement"`
+		BankCredit    money.Amount   `json:"bankcredit"`
+		Arrears       []PayComponent `bson:"-" json:"-"`
+	}
+	// ImportRow One spreadsheet row of a payroll data import and what it will do ...
+	ImportRow struct {
+		Line         int          `json:"line"`
+		EmployeeNo   string       `json:"employeeno"`
+		UserID       string       `json:"userid"`
+		Name         string       `json:"name"`
+		Input        MonthlyInput `json:"input"`
+		Net          money.Amount `json:"net"`
+		Action       string       `json:"action"`
+		PreviousUUID string       `json:"previousuuid"`
+		PreviousNet  money.Amount `json:"previousnet"`
+		Errors       []string     `json:"errors"`
+		Warnings     []string     `json:"warnings"`
+	}
+	// PayrollImport Uploaded spreadsheet of monthly payroll data, checked in a dry
+	// run before it creates payslips ...
+	PayrollImport struct {
+		ImportID    string      `json:"importid"`
+		OrgID       string      `json:"orgid"`
+		Period      string      `json:"period"`
+		Month       time.Time   `json:"month"`
+		FileName    string      `json:"filename"`
+		CreatedBy   string      `json:"createdby"`
+		CreatedOn   time.Time   `json:"createdon"`
+		CommittedBy string      `json:"committedby"`
+		CommittedOn time.Time   `json:"committedon"`
+		Rows        []ImportRow `json:"rows"`
+	}
+	// Organisation A legal entity that employs people and issues their payslips,
+	// with the branding its payslips are printed with. Logo is a base64 PNG or JPEG ...
+	Organisation struct {
+		OrgID         string        `json:"orgid"`
+		Name          string        `json:"name"`
+		LegalName     string        `json:"legalname"`
+		Address       string        `json:"address"`
+		State         string        `json:"state"`
+		PAN           string        `json:"pan"`
+		TAN           string        `json:"tan"`
+		Logo          string        `json:"logo"`
+		BrandColor    string        `json:"brandcolor"`
+		AccentColor   string        `json:"accentcolor"`
+		DebitAccount  string        `json:"debitaccount"`
+		PDFProtection PDFProtection `json:"pdfprotection"`
+		UpdatedBy     string        `json:"updatedby"`
+		UpdatedOn     time.Time     `json:"updatedon"`
+	}
+	// PDFProtection Whether payslip PDFs are encrypted and the rule their password
+	// is derived from, for example {PAN:4}{DOB} ...
+	PDFProtection struct {
+		Enabled      bool   `json:"enabled"`
+		PasswordRule string `json:"passwordrule"`
+	}
+	// ExchangeRate Rupees per unit of a foreign currency from a month on, the rate
+	// in force for a payslip is the latest one up to its month ...
+	ExchangeRate struct {
+		Key       string    `json:"key"`
+		Currency  string    `json:"currency"`
+		Period    string    `json:"period"`
+		Month     time.Time `json:"month"`
+		Rate      float64   `json:"rate"`
+		UpdatedBy string    `json:"updatedby"`
+		UpdatedOn time.Time `json:"updatedon"`
+	}
+	// Arrear A retroactive change of the monthly gross of an employee, such as an
+	// appraisal effective from April applied in June. The difference for every month
+	// from FromMonth to ToMonth that was already paid is paid with the payslip of
+	// PayMonth ...
+	Arrear struct {
+		ArrearID   string       `json:"arrearid"`
+		OrgID      string       `json:"orgid"`
+		UserID     string       `json:"userid"`
+		EmployeeNo string       `json:"employeeno"`
+		Name       string       `json:"name"`
+		FromMonth  time.Time    `json:"frommonth"`
+		ToMonth    time.Time    `json:"tomonth"`
+		PayMonth   time.Time    `json:"paymonth"`
+		PayPeriod  string       `json:"payperiod"`
+		NewGross   money.Amount `json:"newgross"`
+		Reason     string       `json:"reason"`
+		CreatedBy  string       `json:"createdby"`
+		CreatedOn  time.Time    `json:"createdon"`
+	}
+	// PayslipPDF The bytes a payslip PDF was signed with, a signed payslip is always
+	// served as it was signed, PDF is sealed base64 ...
+	PayslipPDF struct {
+		UUID             string    `json:"uuid"`
+		OrgID            string    `json:"orgid"`
+		PDF              string    `json:"pdf"`
+		VerificationCode string    `json:"verificationcode"`
+		SignatureKey     string    `json:"signaturekey"`
+		Signature        string    `json:"signature"`
+		SignedOn         time.Time `json:"signedon"`
+	}
+	// PayrollRunStatus State of a payroll run ...
+	PayrollRunStatus int
+	// PayslipStatus Approval workflow state of a payslip ...
+	PayslipStatus int
+)
+
+// User roles, every signed in user is an employee and admins have every role ...
+const (
+	RoleEmployee = "employee"
+	RoleApprover = "approver"
+	RoleHR       = "hr"
+	RoleAdmin    = "admin"
 )
+
+// DefaultOrgID Id of the first organisation, records saved before there were
+// several organisations belong to it ...
+const DefaultOrgID = "default"
+
+// DefaultBrandColor Colour of payslip headings when an organisation has none ...
+const DefaultBrandColor = "#1AA2FB"
+
+// Roles Roles an admin can grant to users ...
+var Roles = []string{RoleApprover, RoleHR, RoleAdmin}
+
+// Payslip approval workflow states, a payslip moves
+// draft -> submitted -> approved / rejected, approved -> issued ...
+const (
+	PayslipDraft PayslipStatus = iota
+	PayslipSubmitted
+	PayslipApproved
+	PayslipRejected
+	PayslipIssued
+)
+
+// Salary component types ...
+const (
+	ComponentPercentage = "percentage"
+	ComponentFixed      = "fixed"
+	ComponentFormula    = "formula"
+)
+
+// What committing an import row does ...
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+)
+
+// Payroll run states, a run is previewed as often as needed, then locked
+// against changes and finally published to employees ...
+const (
+	RunDraft PayrollRunStatus = iota
+	RunPreviewed
+	RunLocked
+	RunPublished
+)
+
+// String Human readable payroll run status ...
+func (s PayrollRunStatus) String() string {
+	switch s {
+	case RunDraft:
+		return "Draft"
+	case RunPreviewed:
+		return "Previewed"
+	case RunLocked:
+		return "Locked"
+	case RunPublished:
+		return "Published"
+	}
+	return "Unknown"
+}
+
+// payslipTransitions allowed next states for every state ...
+var payslipTransitions = map[PayslipStatus][]PayslipStatus{
+	PayslipDraft:     {PayslipSubmitted},
+	PayslipSubmitted: {PayslipApproved, PayslipRejected},
+	PayslipApproved:  {PayslipIssued},
+}
+
+// String Human readable payslip status ...
+func (s PayslipStatus) String() string {
+	switch s {
+	case PayslipDraft:
+		return "Draft"
+	case PayslipSubmitted:
+		return "Submitted"
+	case PayslipApproved:
+		return "Approved"
+	case PayslipRejected:
+		return "Rejected"
+	case PayslipIssued:
+		return "Issued"
+	}
+	return "Unknown"
+}
+
+// CanTransitionTo Reports whether the workflow allows moving to the next state ...
+func (s PayslipStatus) CanTransitionTo(next PayslipStatus) bool {
+	for _, allowed := range payslipTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDownloadable Only approved payslips have a PDF to hand out ...
+func (s PayslipStatus) IsDownloadable() bool {
+	return s == PayslipApproved || s == PayslipIssued
+}
+
+// HasRole Reports whether the user was granted a role ...
+func (u User) HasRole(role string) bool {
+	if role == RoleEmployee {
+		return true
+	}
+	for _, granted := range u.Roles {
+		if granted == role || granted == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// HasErrors Whether any row of the import failed validation ...
+func (i PayrollImport) HasErrors() bool {
+	for _, row := range i.Rows {
+		if len(row.Errors) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Committed Whether the payslips of the import were created ...
+func (i PayrollImport) Committed() bool {
+	return !i.CommittedOn.IsZero()
+}
+
+// Active Whether the session has neither reached its expiry nor been idle too long ...
+func (s UserSession) Active(now time.Time, idleTimeout time.Duration) bool {
+	return now.Before(s.ExpiresOn) && now.Sub(s.LastSeenOn) < idleTimeout
+}
+
+// Title Name payslips are issued under, the legal name when it is set ...
+func (o Organisation) Title() string {
+	if o.LegalName != "" {
+		return o.LegalName
+	}
+	return o.Name
+}
+
+// OrgIDOr The organisation of a record, records saved before there were several
+// organisations belong to the default one ...
+func OrgIDOr(orgID string) string {
+	if orgID == "" {
+		return DefaultOrgID
+	}
+	return orgID
+}
+
+// CurrencyCode Currency the payslip is paid in ...
+func (p Payslip) CurrencyCode() string {
+	return currency.CodeOr(p.Currency)
+}
+
+// Money An amount of the payslip with its currency, as USD 1,234.50 ...
+func (p Payslip) Money(amount money.Amount) string {
+	return p.CurrencyCode() + " " + currency.Format(amount, p.Currency)
+}
+
+// Money An amount of the result with its currency ...
+func (r PayrollResult) Money(amount money.Amount) string {
+	return currency.CodeOr(r.Currency) + " " + currency.Format(amount, r.Currency)
+}
+
+// Money An amount of the employee with their currency ...
+func (e Employee) Money(amount money.Amount) string {
+	return currency.CodeOr(e.Currency) + " " + currency.Format(amount, e.Currency)
+}
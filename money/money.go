@@ -0,0 +1,118 @@
+// Package money keeps amounts as whole paise so sums are exact. Amounts are only
+// rounded where they are derived from a rate, a percentage or a formula, and then
+// once, half away from zero to the paisa, see FromFloat. Amounts are stored and
+// shown as rupees with two decimals, so records saved as rupees read unchanged.
+// Amounts in other currencies are kept in hundredths of their unit the same way.
+package money
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Amount A sum of money in paise ...
+type Amount int64
+
+// errAmount ...
+var errAmount = errors.New("not an amount")
+
+// FromFloat The amount nearest to a value in rupees, halves are rounded away from
+// zero. This is the rounding policy of the payroll ...
+func FromFloat(rupees float64) Amount {
+	return Amount(math.Round(rupees * 100))
+}
+
+// Rupees An amount of whole rupees ...
+func Rupees(rupees int64) Amount {
+	return Amount(rupees * 100)
+}
+
+// Parse Read an amount written in rupees such as 45210.5 or 1,23,456.78 without
+// going through floating point, more than two decimals are rounded ...
+func Parse(text string) (Amount, error) {
+	text = strings.Replace(strings.TrimSpace(text), ",", "", -1)
+	if text == "" {
+		return 0, nil
+	}
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+	whole, fraction := text, ""
+	if i := strings.IndexByte(text, '.'); i >= 0 {
+		whole, fraction = text[:i], text[i+1:]
+	}
+	if whole == "" && fraction == "" {
+		return 0, errAmount
+	}
+	for _, part := range []string{whole, fraction} {
+		if strings.Trim(part, "0123456789") != "" {
+			return 0, errAmount
+		}
+	}
+	var paise int64
+	if whole != "" {
+		rupees, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || rupees > math.MaxInt64/100-1 {
+			return 0, errAmount
+		}
+		paise = rupees * 100
+	}
+	fraction += "000"
+	cents, _ := strconv.ParseInt(fraction[:2], 10, 64)
+	paise += cents
+	if fraction[2] >= '5' {
+		paise++
+	}
+	if negative {
+		paise = -paise
+	}
+	return Amount(paise), nil
+}
+
+// Float The amount in rupees, for rates and tax slabs that are worked out in
+// floating point ...
+func (a Amount) Float() float64 {
+	return float64(a) / 100
+}
+
+// Times The amount multiplied by a rate, rounded to the paisa ...
+func (a Amount) Times(rate float64) Amount {
+	return FromFloat(a.Float() * rate)
+}
+
+// String The amount in rupees with two decimals, as 45210.50 ...
+func (a Amount) String() string {
+	sign, paise := "", int64(a)
+	if paise < 0 {
+		sign, paise = "-", -paise
+	}
+	return sign + strconv.FormatInt(paise/100, 10) + "." + strconv.FormatInt(paise%100/10, 10) + strconv.FormatInt(paise%10, 10)
+}
+
+// UnmarshalText Read an amount entered in a form, an empty field is zero ...
+func (a *Amount) UnmarshalText(text []byte) error {
+	amount, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// GetBSON Store the amount as rupees like amounts were stored before ...
+func (a Amount) GetBSON() (interface{}, error) {
+	return a.Float(), nil
+}
+
+// SetBSON Read an amount stored as rupees, whole or with decimals ...
+func (a *Amount) SetBSON(raw bson.Raw) error {
+	var rupees float64
+	if err := raw.Unmarshal(&rupees); err != nil {
+		return err
+	}
+	*a = FromFloat(rupees)
+	return nil
+}
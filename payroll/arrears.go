@@ -0,0 +1,82 @@
+package payroll
+
+import (
+	"time"
+
+	"bcpayslip/models"
+	"bcpayslip/money"
+)
+
+// ArrearMonths The months an arrear covers, from the first month to the last ...
+func ArrearMonths(from time.Time, to time.Time) []time.Time {
+	var months []time.Time
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !month.After(last) {
+		months = append(months, month)
+		month = month.AddDate(0, 1, 0)
+	}
+	return months
+}
+
+// Arrears Back pay lines for a new monthly gross that applies to months already
+// paid. For every paid payslip the structure splits the new gross, prorated over
+// the days paid that month, and the earnings paid are taken off component by
+// component. The differences are summed per component in the order of the
+// structure, a component that did not change gives no line. A lower gross gives
+// negative lines that recover what was overpaid ...
+func Arrears(structure models.SalaryStructure, newGross money.Amount, paid []models.Payslip) ([]models.PayComponent, error) {
+	earnings, err := ComputeEarnings(structure, newGross)
+	if err != nil {
+		return nil, err
+	}
+	var lines []models.PayComponent
+	index := make(map[string]int)
+	add := func(earning models.PayComponent, amount money.Amount) {
+		i, ok := index[earning.Code]
+		if !ok {
+			i = len(lines)
+			index[earning.Code] = i
+			lines = append(lines, models.PayComponent{Name: earning.Name, Code: earning.Code, Arrear: true})
+		}
+		lines[i].Amount += amount
+	}
+	for _, payslip := range paid {
+		for _, earning := range Prorate(earnings, payslip.PaidDays, payslip.WorkingDays) {
+			add(earning, earning.Amount)
+		}
+		for _, earning := range payslip.Earnings {
+			if !isOneOff(earning) {
+				add(earning, -earning.Amount)
+			}
+		}
+	}
+	arrears := make([]models.PayComponent, 0, len(lines))
+	for _, line := range lines {
+		if line.Amount != 0 {
+			arrears = append(arrears, line)
+		}
+	}
+	return arrears, nil
+}
+
+// HasArrears Whether payslip lines include back pay ...
+func HasArrears(components []models.PayComponent) bool {
+	for _, component := range components {
+		if component.Arrear {
+			return true
+		}
+	}
+	return false
+}
+
+// arrearsIn Sum of the arrear lines among payslip lines ...
+func arrearsIn(components []models.PayComponent) money.Amount {
+	var total money.Amount
+	for _, component := range components {
+		if component.Arrear {
+			total += component.Amount
+		}
+	}
+	return total
+}
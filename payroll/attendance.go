@@ -0,0 +1,78 @@
+package payroll
+
+import (
+	"math"
+	"time"
+
+	"bcpayslip/models"
+)
+
+// ApplyAttendance Fill the working, paid and loss of pay days of a payslip for its
+// month. Working days are the calendar days of the month unless a number is
+// entered, such as 26 for a six day week. Days before the date of joining and after
+// the date of leaving are not paid and neither are loss of pay days. Paid days
+// entered for the month, from an attendance system for example, are taken as they
+// are ...
+func ApplyAttendance(payslip *models.Payslip, input models.MonthlyInput) {
+	days := float64(DaysIn(payslip.Month))
+	working := input.WorkingDays
+	if working <= 0 {
+		working = days
+	}
+	paid := input.PaidDays
+	if paid <= 0 {
+		// days outside the employment count in proportion to the working days
+		away := notEmployed(payslip.Month, payslip.DateOfJoining, payslip.DateOfLeaving)
+		paid = working - math.Round(away*working/days*100)/100 - input.LOPDays
+	}
+	payslip.WorkingDays = working
+	payslip.PaidDays = math.Max(0, math.Min(paid, working))
+	payslip.LOPDays = input.LOPDays
+}
+
+// notEmployed Calendar days of a month before the date of joining or after the
+// date of leaving ...
+func notEmployed(month time.Time, joined time.Time, left time.Time) float64 {
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	last := PayDate(month)
+	days := float64(DaysIn(month))
+	var away float64
+	if !joined.IsZero() {
+		joined = time.Date(joined.Year(), joined.Month(), joined.Day(), 0, 0, 0, 0, time.UTC)
+		switch {
+		case joined.After(last):
+			return days
+		case joined.After(first):
+			away += joined.Sub(first).Hours() / 24
+		}
+	}
+	if !left.IsZero() {
+		left = time.Date(left.Year(), left.Month(), left.Day(), 0, 0, 0, 0, time.UTC)
+		switch {
+		case left.Before(first):
+			return days
+		case left.Before(last):
+			away += last.Sub(left).Hours() / 24
+		}
+	}
+	return math.Min(away, days)
+}
+
+// Prorate Pay every earning for the share of the working days that are paid, each
+// is rounded to the paisa and a rounding adjustment keeps their total at the same
+// share of the full salary ...
+func Prorate(earnings []models.PayComponent, paidDays float64, workingDays float64) []models.PayComponent {
+	if workingDays <= 0 || paidDays >= workingDays {
+		return earnings
+	}
+	share := paidDays / workingDays
+	prorated := make([]models.PayComponent, 0, len(earnings))
+	for _, earning := range earnings {
+		if earning.Code == RoundingCode {
+			continue
+		}
+		earning.Amount = earning.Amount.Times(share)
+		prorated = append(prorated, earning)
+	}
+	return reconcile(prorated, Total(earnings).Times(share))
+}
@@ -0,0 +1,179 @@
+package payroll
+
+import (
+	"bcpayslip/currency"
+	"bcpayslip/models"
+	"bcpayslip/money"
+	"bcpayslip/statutory"
+	"bcpayslip/tax"
+)
+
+// Codes of the payslip lines other parts of the payroll look up ...
+const (
+	BasicCode           = "basic"
+	HRACode             = "hra"
+	IncomeTaxCode       = "tds"
+	ProvidentFundCode   = "pf"
+	PensionCode         = "eps"
+	ESICode             = "esi"
+	ProfessionalTaxCode = "pt"
+	BonusCode           = "bonus"
+	ReimbursementCode   = "reimbursement"
+	RoundingCode        = "rounding"
+)
+
+// isOneOff Earnings paid only in the month of the payslip rather than every month,
+// they are kept when the salary structure is applied again. They are added on the
+// server only, from the monthly input and the arrears HR recorded ...
+func isOneOff(earning models.PayComponent) bool {
+	return earning.Arrear || earning.Code == BonusCode || earning.Code == ReimbursementCode
+}
+
+// AddOneOff Add a one-off earning to a payslip before it is calculated, a bonus is
+// taxed in the month it is paid while a reimbursement is not taxed ...
+func AddOneOff(payslip *models.Payslip, code string, amount money.Amount) {
+	if amount <= 0 {
+		return
+	}
+	name := "Bonus"
+	if code == ReimbursementCode {
+		name = "Reimbursement"
+	}
+	payslip.Earnings = append(payslip.Earnings, models.PayComponent{Name: name, Code: code, Amount: amount})
+}
+
+// Total Sum of payslip lines ...
+func Total(components []models.PayComponent) money.Amount {
+	var total money.Amount
+	for _, component := range components {
+		total += component.Amount
+	}
+	return total
+}
+
+// AmountOf Amount of the payslip line with a code, zero if there is none ...
+func AmountOf(components []models.PayComponent, code string) money.Amount {
+	for _, component := range components {
+		if component.Code == code {
+			return component.Amount
+		}
+	}
+	return 0
+}
+
+// Calculate Fill the earnings, deductions, employer contributions and net pay of a
+// payslip from its gross salary, the salary structure, the state the employee works
+// in and their tax declaration. Payslips in a foreign currency are for overseas
+// contractors, Indian tax and social security do not apply to them ...
+func Calculate(payslip *models.Payslip, structure models.SalaryStructure) error {
+	var oneOff []models.PayComponent
+	for _, earning := range payslip.Earnings {
+		if isOneOff(earning) {
+			oneOff = append(oneOff, earning)
+		}
+	}
+	if err := ApplyStructure(payslip, structure); err != nil {
+		return err
+	}
+	if currency.IsForeign(payslip.Currency) {
+		payslip.Earnings = append(payslip.Earnings, oneOff...)
+		payslip.TDS = 0
+		payslip.Deductions = nil
+		payslip.EmployerContributions = nil
+		payslip.AmountReceivedBank = Total(payslip.Earnings)
+		return nil
+	}
+	gross := Total(payslip.Earnings)
+	basic := AmountOf(payslip.Earnings, BasicCode)
+	// statutory contributions and tax are worked out in rupees from slabs and
+	// rates, each is rounded to the paisa once it becomes a payslip line
+	contributions := statutory.Compute(payslip.State, basic.Float(), gross.Float(), payslip.Month)
+	income := tax.Income{
+		Gross:           gross.Float() * 12,
+		Basic:           basic.Float() * 12,
+		HRA:             AmountOf(payslip.Earnings, HRACode).Float() * 12,
+		ProfessionalTax: statutory.AnnualProfessionalTax(payslip.State, gross.Float()),
+		Declaration:     payslip.Declaration,
+	}
+	monthlyTDS, err := tax.MonthlyTDS(payslip.Month, income)
+	if err != nil {
+		return err
+	}
+	tds := money.FromFloat(monthlyTDS)
+	// arrears are taxed in the month they are paid like a bonus
+	if extra := AmountOf(oneOff, BonusCode) + arrearsIn(oneOff); extra > 0 {
+		extraTDS, err := tax.OneOffTDS(payslip.Month, income, extra.Float())
+		if err != nil {
+			return err
+		}
+		tds += money.FromFloat(extraTDS)
+	}
+	payslip.Earnings = append(payslip.Earnings, oneOff...)
+	payslip.TDS = tds
+	payslip.Deductions = []models.PayComponent{
+		{Name: "Income Tax", Code: IncomeTaxCode, Amount: tds},
+		{Name: "Provident Fund", Code: ProvidentFundCode, Amount: money.FromFloat(contributions.EmployeePF)},
+	}
+	payslip.EmployerContributions = []models.PayComponent{
+		{Name: "Employer Provident Fund", Code: ProvidentFundCode, Amount: money.FromFloat(contributions.EmployerPF)},
+		{Name: "Employer Pension Scheme", Code: PensionCode, Amount: money.FromFloat(contributions.EmployerPension)},
+	}
+	if contributions.ESIApplicable {
+		payslip.Deductions = append(payslip.Deductions,
+			models.PayComponent{Name: "ESI", Code: ESICode, Amount: money.FromFloat(contributions.EmployeeESI)})
+		payslip.EmployerContributions = append(payslip.EmployerContributions,
+			models.PayComponent{Name: "Employer ESI", Code: ESICode, Amount: money.FromFloat(contributions.EmployerESI)})
+	}
+	if contributions.PTApplicable {
+		payslip.Deductions = append(payslip.Deductions,
+			models.PayComponent{Name: "Profession Tax", Code: ProfessionalTaxCode, Amount: money.FromFloat(contributions.ProfessionalTax)})
+	}
+	payslip.AmountReceivedBank = Total(payslip.Earnings) - Total(payslip.Deductions)
+	return nil
+}
+
+// Backfill Fill the lines of payslips saved before they were calculated, the
+// difference between gross and the amount received was shown as income tax ...
+func Backfill(payslip *models.Payslip) {
+	if len(payslip.Earnings) > 0 {
+		return
+	}
+	ApplyStructure(payslip, DefaultStructure())
+	payslip.Deductions = []models.PayComponent{
+		{Name: "Income Tax", Code: IncomeTaxCode, Amount: payslip.GrossAnnualSalary - payslip.AmountReceivedBank},
+	}
+}
+
+// ApplyEmployee Copy the employee master record onto a payslip ...
+func ApplyEmployee(payslip *models.Payslip, employee models.Employee) {
+	payslip.Name = employee.Name
+	payslip.EmployeeNo = employee.EmployeeNo
+	payslip.Position = employee.Designation
+	payslip.Department = employee.Department
+	payslip.Grade = employee.Grade
+	payslip.DateOfJoining = employee.DateOfJoining
+	payslip.DateOfLeaving = employee.DateOfLeaving
+	payslip.DateOfBirth = employee.DateOfBirth
+	payslip.PAN = employee.PAN
+	payslip.UAN = employee.UAN
+	payslip.AccountNo = employee.AccountNo
+	payslip.IFSCCode = employee.IFSCCode
+	// a record without a state leaves the state entered on the payslip
+	if employee.State != "" {
+		payslip.State = employee.State
+	}
+	payslip.OrgID = models.OrgIDOr(employee.OrgID)
+	payslip.Currency = currency.CodeOr(employee.Currency)
+}
+
+// ApplyEmployeePay Take the monthly gross and tax declaration HR keeps in the
+// master record onto a payslip an employee asks for, payroll runs and imports
+// bring their own figures for the month ...
+func ApplyEmployeePay(payslip *models.Payslip, employee models.Employee) {
+	if employee.MonthlyGross > 0 {
+		payslip.GrossAnnualSalary = employee.MonthlyGross
+	}
+	if employee.Declaration != (models.TaxDeclaration{}) {
+		payslip.Declaration = employee.Declaration
+	}
+}
@@ -0,0 +1,249 @@
+package payroll
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Formulas are arithmetic expressions over component codes and gross, e.g.
+// "min(basic * 0.5, 15000)" or "gross - basic - hra". Grammar:
+//
+//	expr   := term {("+" | "-") term}
+//	term   := factor {("*" | "/") factor}
+//	factor := number | ident | ident "(" expr {"," expr} ")" | "(" expr ")" | "-" factor
+
+// formulaNode A parsed formula expression ...
+type formulaNode interface {
+	eval(env map[string]float64) (float64, error)
+	idents() []string
+}
+
+type numberNode float64
+
+type identNode string
+
+type unaryNode struct {
+	operand formulaNode
+}
+
+type binaryNode struct {
+	op          byte
+	left, right formulaNode
+}
+
+type callNode struct {
+	name string
+	args []formulaNode
+}
+
+func (n numberNode) eval(env map[string]float64) (float64, error) { return float64(n), nil }
+
+func (n numberNode) idents() []string { return nil }
+
+func (n identNode) eval(env map[string]float64) (float64, error) {
+	value, ok := env[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("unknown component %q", string(n))
+	}
+	return value, nil
+}
+
+func (n identNode) idents() []string { return []string{string(n)} }
+
+func (n unaryNode) eval(env map[string]float64) (float64, error) {
+	value, err := n.operand.eval(env)
+	return -value, err
+}
+
+func (n unaryNode) idents() []string { return n.operand.idents() }
+
+func (n binaryNode) eval(env map[string]float64) (float64, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	}
+	if right == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return left / right, nil
+}
+
+func (n binaryNode) idents() []string { return append(n.left.idents(), n.right.idents()...) }
+
+func (n callNode) eval(env map[string]float64) (float64, error) {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = value
+	}
+	switch n.name {
+	case "min", "max":
+		result := args[0]
+		for _, arg := range args[1:] {
+			if n.name == "min" {
+				result = math.Min(result, arg)
+			} else {
+				result = math.Max(result, arg)
+			}
+		}
+		return result, nil
+	case "round":
+		if len(args) != 1 {
+			return 0, errors.New("round takes one argument")
+		}
+		// halves away from zero, like money.FromFloat
+		return math.Round(args[0]), nil
+	}
+	return 0, fmt.Errorf("unknown function %q", n.name)
+}
+
+func (n callNode) idents() []string {
+	var idents []string
+	for _, arg := range n.args {
+		idents = append(idents, arg.idents()...)
+	}
+	return idents
+}
+
+// formulaParser Recursive descent parser over a formula string ...
+type formulaParser struct {
+	src string
+	pos int
+}
+
+// parseFormula Parse a formula into an expression tree ...
+func parseFormula(src string) (formulaNode, error) {
+	p := &formulaParser{src: src}
+	node, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.src[p.pos], p.pos+1)
+	}
+	return node, nil
+}
+
+func (p *formulaParser) skipSpaces() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *formulaParser) peek() byte {
+	p.skipSpaces()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *formulaParser) expr() (formulaNode, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *formulaParser) term() (formulaNode, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *formulaParser) factor() (formulaNode, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, errors.New("unexpected end of formula")
+	case c == '-':
+		p.pos++
+		operand, err := p.factor()
+		return unaryNode{operand: operand}, err
+	case c == '(':
+		p.pos++
+		node, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at position %d", p.pos+1)
+		}
+		p.pos++
+		return node, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '.' || (p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		return numberNode(value), err
+	case c == '_' || unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+		name := strings.ToLower(p.src[start:p.pos])
+		if p.peek() != '(' {
+			return identNode(name), nil
+		}
+		p.pos++
+		call := callNode{name: name}
+		for {
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.peek() == ',' {
+				p.pos++
+				continue
+			}
+			if p.peek() != ')' {
+				return nil, fmt.Errorf("missing ) at position %d", p.pos+1)
+			}
+			p.pos++
+			return call, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+}
@@ -0,0 +1,88 @@
+package payroll
+
+import (
+	"sync"
+	"time"
+
+	"bcpayslip/models"
+)
+
+// RunEach Call work for every employee with at most workers calls running at once,
+// results are returned in the order of the employees ...
+func RunEach(employees []models.Employee, workers int, work func(models.Employee) models.PayrollResult) []models.PayrollResult {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]models.PayrollResult, len(employees))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = work(employees[i])
+			}
+		}()
+	}
+	for i := range employees {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// PayDate Salaries are credited on the last day of the pay period ...
+func PayDate(month time.Time) time.Time {
+	firstDay := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return firstDay.AddDate(0, 1, -1)
+}
+
+// DaysIn Calendar days of the pay period ...
+func DaysIn(month time.Time) int {
+	return PayDate(month).Day()
+}
+
+// PayslipFor Calculate the payslip of an employee for a month from their master record ...
+func PayslipFor(employee models.Employee, month time.Time, structure models.SalaryStructure) (models.Payslip, error) {
+	return PayslipWith(employee, month, structure, models.MonthlyInput{Gross: employee.MonthlyGross})
+}
+
+// PayslipWith Calculate the payslip of an employee for a month from the figures
+// entered for that month ...
+func PayslipWith(employee models.Employee, month time.Time, structure models.SalaryStructure, input models.MonthlyInput) (models.Payslip, error) {
+	payslip := models.Payslip{
+		PayslipID:         employee.UserID,
+		Month:             time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC),
+		Day:               PayDate(month),
+		GrossAnnualSalary: input.Gross,
+		Declaration:       employee.Declaration,
+	}
+	ApplyEmployee(&payslip, employee)
+	ApplyAttendance(&payslip, input)
+	AddOneOff(&payslip, BonusCode, input.Bonus)
+	AddOneOff(&payslip, ReimbursementCode, input.Reimbursement)
+	payslip.Earnings = append(payslip.Earnings, input.Arrears...)
+	err := Calculate(&payslip, structure)
+	return payslip, err
+}
+
+// ResultOf Summary of a calculated payslip for a payroll run ...
+func ResultOf(employee models.Employee, payslip models.Payslip, err error) models.PayrollResult {
+	result := models.PayrollResult{
+		UserID:     employee.UserID,
+		EmployeeNo: employee.EmployeeNo,
+		Name:       employee.Name,
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.PayslipUUID = payslip.UUID
+	result.Gross = Total(payslip.Earnings)
+	result.Deductions = Total(payslip.Deductions)
+	result.Net = payslip.AmountReceivedBank
+	result.Currency = payslip.CurrencyCode()
+	return result
+}
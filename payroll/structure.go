@@ -0,0 +1,144 @@
+package payroll
+
+import (
+	"fmt"
+	"strings"
+
+	"bcpayslip/models"
+	"bcpayslip/money"
+)
+
+// GrossCode Formula identifier of the monthly gross salary ...
+const GrossCode = "gross"
+
+// DefaultStructure The structure used when nothing is assigned to an employee ...
+func DefaultStructure() models.SalaryStructure {
+	return models.SalaryStructure{
+		Name: "Default",
+		Components: []models.SalaryComponent{
+			{Name: "Basic Salary", Code: "basic", Type: models.ComponentPercentage, Value: 60, Of: GrossCode},
+			{Name: "House Rent Allowance", Code: "hra", Type: models.ComponentPercentage, Value: 20, Of: GrossCode},
+			{Name: "Special / Conv Allowance", Code: "special", Type: models.ComponentPercentage, Value: 15, Of: GrossCode},
+			{Name: "Other Allowance", Code: "other", Type: models.ComponentPercentage, Value: 5, Of: GrossCode},
+		},
+	}
+}
+
+// ComputeEarnings Split a monthly gross salary into the structure components. Components
+// may refer to each other, so they are evaluated in dependency order but returned in
+// the order they are declared. Every component is rounded to the paisa, when that
+// leaves the components a few paise off the gross a rounding adjustment line makes
+// up the difference. Larger differences are left alone, those are structures that
+// do not split the whole gross ...
+func ComputeEarnings(structure models.SalaryStructure, gross money.Amount) ([]models.PayComponent, error) {
+	formulas := make(map[string]formulaNode)
+	for _, component := range structure.Components {
+		code := strings.ToLower(component.Code)
+		if code == "" || code == GrossCode {
+			return nil, fmt.Errorf("component %q needs a code other than %q", component.Name, GrossCode)
+		}
+		if _, ok := formulas[code]; ok {
+			return nil, fmt.Errorf("component code %q is used twice", code)
+		}
+		node, err := componentFormula(component)
+		if err != nil {
+			return nil, fmt.Errorf("component %q: %s", component.Name, err)
+		}
+		formulas[code] = node
+	}
+	env := map[string]float64{GrossCode: gross.Float()}
+	visiting := make(map[string]bool)
+	var evaluate func(code string) error
+	evaluate = func(code string) error {
+		if _, ok := env[code]; ok {
+			return nil
+		}
+		node, ok := formulas[code]
+		if !ok {
+			return fmt.Errorf("unknown component %q", code)
+		}
+		if visiting[code] {
+			return fmt.Errorf("component %q refers to itself", code)
+		}
+		visiting[code] = true
+		for _, ident := range node.idents() {
+			if err := evaluate(ident); err != nil {
+				return err
+			}
+		}
+		amount, err := node.eval(env)
+		if err != nil {
+			return fmt.Errorf("component %q: %s", code, err)
+		}
+		// later components see the rounded amounts that are printed
+		env[code] = money.FromFloat(amount).Float()
+		return nil
+	}
+	earnings := make([]models.PayComponent, 0, len(structure.Components))
+	for _, component := range structure.Components {
+		code := strings.ToLower(component.Code)
+		if err := evaluate(code); err != nil {
+			return nil, err
+		}
+		earnings = append(earnings, models.PayComponent{Name: component.Name, Code: code, Amount: money.FromFloat(env[code])})
+	}
+	return reconcile(earnings, gross), nil
+}
+
+// reconcile Add a rounding adjustment line when rounding left the earnings a few
+// paise off their total ...
+func reconcile(earnings []models.PayComponent, total money.Amount) []models.PayComponent {
+	if adjustment := total - Total(earnings); adjustment != 0 && isRounding(adjustment, len(earnings)) {
+		earnings = append(earnings, models.PayComponent{Name: "Rounding Adjustment", Code: RoundingCode, Amount: adjustment})
+	}
+	return earnings
+}
+
+// isRounding Whether a difference between the gross and its components can come
+// from rounding them, at most a paisa for each component ...
+func isRounding(difference money.Amount, components int) bool {
+	if difference < 0 {
+		difference = -difference
+	}
+	return difference <= money.Amount(components)
+}
+
+// componentFormula Express every component type as a formula ...
+func componentFormula(component models.SalaryComponent) (formulaNode, error) {
+	switch component.Type {
+	case models.ComponentPercentage:
+		of := strings.ToLower(strings.TrimSpace(component.Of))
+		if of == "" {
+			of = GrossCode
+		}
+		return binaryNode{op: '*', left: identNode(of), right: numberNode(component.Value / 100)}, nil
+	case models.ComponentFixed:
+		return numberNode(component.Value), nil
+	case models.ComponentFormula:
+		return parseFormula(component.Formula)
+	}
+	return nil, fmt.Errorf("unknown component type %q", component.Type)
+}
+
+// ValidateStructure Check a structure evaluates without errors ...
+func ValidateStructure(structure models.SalaryStructure) error {
+	if strings.TrimSpace(structure.Name) == "" {
+		return fmt.Errorf("structure needs a name")
+	}
+	if len(structure.Components) == 0 {
+		return fmt.Errorf("structure needs at least one component")
+	}
+	_, err := ComputeEarnings(structure, money.Rupees(100000))
+	return err
+}
+
+// ApplyStructure Fill the payslip earnings from its gross salary, prorated to the
+// days paid ...
+func ApplyStructure(payslip *models.Payslip, structure models.SalaryStructure) error {
+	earnings, err := ComputeEarnings(structure, payslip.GrossAnnualSalary)
+	if err != nil {
+		return err
+	}
+	payslip.Earnings = Prorate(earnings, payslip.PaidDays, payslip.WorkingDays)
+	return nil
+}
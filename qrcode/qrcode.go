@@ -0,0 +1,427 @@
+// Package qrcode encodes short texts such as urls as QR codes (ISO/IEC 18004) in
+// byte mode with error correction level M, versions 1 to 10, up to 213 bytes.
+package qrcode
+
+import "errors"
+
+// ErrTooLong The data does not fit in a version 10 QR code ...
+var ErrTooLong = errors.New("qrcode: data is too long")
+
+// Code A QR code, a square of dark and light modules without the quiet zone ...
+type Code struct {
+	Size     int
+	Version  int
+	Mask     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// version Layout of a version at error correction level M, data codewords are
+// split in blocks of Short and Short+1 codewords that each get ECC codewords ...
+type version struct {
+	ECC       int
+	Short     int
+	Blocks    int
+	Long      int
+	Alignment []int
+}
+
+var versions = []version{
+	{},
+	{ECC: 10, Short: 16, Blocks: 1},
+	{ECC: 16, Short: 28, Blocks: 1, Alignment: []int{6, 18}},
+	{ECC: 26, Short: 44, Blocks: 1, Alignment: []int{6, 22}},
+	{ECC: 18, Short: 32, Blocks: 2, Alignment: []int{6, 26}},
+	{ECC: 24, Short: 43, Blocks: 2, Alignment: []int{6, 30}},
+	{ECC: 16, Short: 27, Blocks: 4, Alignment: []int{6, 34}},
+	{ECC: 18, Short: 31, Blocks: 4, Alignment: []int{6, 22, 38}},
+	{ECC: 22, Short: 38, Blocks: 2, Long: 2, Alignment: []int{6, 24, 42}},
+	{ECC: 22, Short: 36, Blocks: 3, Long: 2, Alignment: []int{6, 26, 46}},
+	{ECC: 26, Short: 43, Blocks: 4, Long: 1, Alignment: []int{6, 28, 50}},
+}
+
+// dataCodewords ...
+func (v version) dataCodewords() int {
+	return v.Short*v.Blocks + (v.Short+1)*v.Long
+}
+
+// Encode The smallest QR code that holds the data ...
+func Encode(data []byte) (*Code, error) {
+	for number := 1; number < len(versions); number++ {
+		countBits := 8
+		if number >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) > 8*versions[number].dataCodewords() {
+			continue
+		}
+		code := &Code{Version: number, Size: 17 + 4*number}
+		code.modules = make([][]bool, code.Size)
+		code.function = make([][]bool, code.Size)
+		for y := range code.modules {
+			code.modules[y] = make([]bool, code.Size)
+			code.function[y] = make([]bool, code.Size)
+		}
+		code.drawFunctionPatterns()
+		code.drawCodewords(addECC(versions[number], dataBits(data, countBits, versions[number].dataCodewords())))
+		code.chooseMask()
+		return code, nil
+	}
+	return nil, ErrTooLong
+}
+
+// Dark Whether the module in column x and row y is dark ...
+func (c *Code) Dark(x int, y int) bool {
+	return c.modules[y][x]
+}
+
+// dataBits Mode, length and data padded to the capacity of the version ...
+func dataBits(data []byte, countBits int, capacity int) []byte {
+	var bits []bool
+	appendBits := func(value int, length int) {
+		for i := length - 1; i >= 0; i-- {
+			bits = append(bits, (value>>uint(i))&1 == 1)
+		}
+	}
+	appendBits(4, 4) // byte mode
+	appendBits(len(data), countBits)
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	terminator := 8*capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	appendBits(0, terminator)
+	appendBits(0, (8-len(bits)%8)%8)
+	codewords := make([]byte, 0, capacity)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << uint(7-j)
+			}
+		}
+		codewords = append(codewords, b)
+	}
+	for pad := byte(0xEC); len(codewords) < capacity; pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+	return codewords
+}
+
+// addECC Split the data codewords in blocks, add the error correction codewords of
+// each block and interleave the blocks ...
+func addECC(v version, data []byte) []byte {
+	divisor := reedSolomonDivisor(v.ECC)
+	var blocks, eccs [][]byte
+	for i := 0; i < v.Blocks+v.Long; i++ {
+		length := v.Short
+		if i >= v.Blocks {
+			length++
+		}
+		block := data[:length]
+		data = data[length:]
+		blocks = append(blocks, block)
+		eccs = append(eccs, reedSolomonRemainder(block, divisor))
+	}
+	var result []byte
+	for i := 0; i <= v.Short; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < v.ECC; i++ {
+		for _, ecc := range eccs {
+			result = append(result, ecc[i])
+		}
+	}
+	return result
+}
+
+// gfMultiply Product in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1 ...
+func gfMultiply(x byte, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+// reedSolomonDivisor Generator polynomial of a degree, highest coefficient first
+// and without the leading 1 ...
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// reedSolomonRemainder Error correction codewords of a block ...
+func reedSolomonRemainder(data []byte, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMultiply(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// set Set a function module ...
+func (c *Code) set(x int, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// drawFunctionPatterns Timing, finder and alignment patterns, and room for the
+// format and version information ...
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	for _, corner := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				if x >= 0 && x < c.Size && y >= 0 && y < c.Size {
+					distance := max(abs(dx), abs(dy))
+					c.set(x, y, distance != 2 && distance != 4)
+				}
+			}
+		}
+	}
+	alignment := versions[c.Version].Alignment
+	last := len(alignment) - 1
+	for i, cx := range alignment {
+		for j, cy := range alignment {
+			// the corners taken by finder patterns
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	c.drawFormatBits(0)
+	c.drawVersionBits()
+}
+
+// formatBits Format information of level M and a mask ...
+func formatBits(mask int) int {
+	data := mask // level M is 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// drawFormatBits ...
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>uint(i))&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// versionBits Version information, only versions 7 and up carry it ...
+func versionBits(number int) int {
+	rem := number
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return number<<12 | rem
+}
+
+// drawVersionBits ...
+func (c *Code) drawVersionBits() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionBits(c.Version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 == 1
+		a, b := c.Size-11+i%3, i/3
+		c.set(a, b, dark)
+		c.set(b, a, dark)
+	}
+}
+
+// drawCodewords Place the codewords in two module wide columns zigzagging up and
+// down from the bottom right, skipping function modules ...
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.function[y][x] && i < len(codewords)*8 {
+					c.modules[y][x] = (codewords[i>>3]>>uint(7-i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// masked Whether a mask inverts the module in column x and row y ...
+func masked(mask int, x int, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask Invert the data modules a mask selects, applying it twice undoes it ...
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.function[y][x] && masked(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// chooseMask Apply the mask with the lowest penalty ...
+func (c *Code) chooseMask() {
+	best, lowest := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if score := c.penalty(); lowest < 0 || score < lowest {
+			best, lowest = mask, score
+		}
+		c.applyMask(mask)
+	}
+	c.Mask = best
+	c.applyMask(best)
+	c.drawFormatBits(best)
+}
+
+// penalty Score of the four penalty rules, lower reads better ...
+func (c *Code) penalty() int {
+	score := 0
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	for _, vertical := range []bool{false, true} {
+		at := func(line int, i int) bool {
+			if vertical {
+				return c.modules[i][line]
+			}
+			return c.modules[line][i]
+		}
+		for line := 0; line < c.Size; line++ {
+			// runs of five or more modules of one colour
+			run := 1
+			for i := 1; i <= c.Size; i++ {
+				if i < c.Size && at(line, i) == at(line, i-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += 3 + run - 5
+				}
+				run = 1
+			}
+			// patterns that look like finder patterns
+			for i := 0; i+11 <= c.Size; i++ {
+				for _, pattern := range finderLike {
+					same := true
+					for k, dark := range pattern {
+						if at(line, i+k) != dark {
+							same = false
+							break
+						}
+					}
+					if same {
+						score += 40
+					}
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			// 2x2 blocks of one colour
+			if x+1 < c.Size && y+1 < c.Size {
+				color := c.modules[y][x]
+				if c.modules[y][x+1] == color && c.modules[y+1][x] == color && c.modules[y+1][x+1] == color {
+					score += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	deviation := abs(dark*20-total*10) / total
+	return score + deviation*10
+}
+
+// abs ...
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
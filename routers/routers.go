@@ -3,14 +3,25 @@ package routers
 import (
 	"net/http"
 
+	"bcpayslip/auth"
 	"bcpayslip/controllers"
 	"bcpayslip/middlewares"
+	"bcpayslip/models"
 	"bcpayslip/urls"
 
 	"github.com/gorilla/pat"
 	"github.com/urfave/negroni"
 )
 
+// withRole Guard a group of routes with the role its users need ...
+func withRole(role string, routes http.Handler) http.Handler {
+	return negroni.New(
+		negroni.HandlerFunc(
+			middlewares.RequireRole(role)),
+		negroni.Wrap(routes),
+	)
+}
+
 // GetRouter registers all routes for the application ...
 func GetRouter() *pat.Router {
 	// url paths imported from urls package
@@ -18,18 +29,86 @@ func GetRouter() *pat.Router {
 	// static route
 	common.PathPrefix(urls.StaticPath).Handler(
 		http.StripPrefix(urls.StaticPath, http.FileServer(http.Dir("static"))))
-	// media route
-	common.PathPrefix(urls.MediaPath).Handler(
-		http.StripPrefix(urls.MediaPath, http.FileServer(http.Dir("media"))))
 	// common routes
 	common.Get(urls.AuthcallbackPath, controllers.AuthCallbackController)
 	common.Get(urls.AuthPath, controllers.AuthController)
 	common.Get(urls.LogoutPath, controllers.LogoutController)
-	// payslip routes
+	// stand-in identity provider for trying logins offline in development
+	if stub := auth.StubHandler(); stub != nil {
+		common.PathPrefix(auth.StubPath + "/").Handler(http.StripPrefix(auth.StubPath, stub))
+	}
+	// signed payslip links shared outside the app, e.g. with a bank
+	common.Get(urls.SharedPayslipPath, controllers.SharedPayslipController)
+	// public verification of the code printed on payslips
+	common.Get(urls.VerifyPath, controllers.VerifyController)
+	common.Post(urls.VerifyPath, controllers.VerifyController)
+	common.Get(urls.VerifyFormPath, controllers.VerifyFormController)
+	// payslip routes, every signed in user is an employee
+	// pat matches path prefixes, so HomePath has to be registered last
 	payslip := pat.New()
-	payslip.Get(urls.HomePath, controllers.PayslipController)
+	payslip.Post(urls.PayslipSubmitPath, controllers.SubmitPayslipController)
+	payslip.Get(urls.PayslipDownloadPath, controllers.PayslipDownloadController)
+	payslip.Get(urls.PayslipSharePath, controllers.PayslipShareController)
+	payslip.Get(urls.PayslipsPath, controllers.PayslipHistoryController)
 	payslip.Get(urls.PayslipPath, controllers.PayslipController)
 	payslip.Post(urls.PayslipPath, controllers.PayslipController)
+	// login sessions of the user
+	payslip.Post(urls.SignOutEverywherePath, controllers.SignOutEverywhereController)
+	payslip.Get(urls.SessionsPath, controllers.SessionsController)
+	// employees see their own record, HR edits it
+	payslip.Get(urls.ProfileEditPath, controllers.ProfileEditController)
+	payslip.Post(urls.ProfileEditPath, controllers.ProfileEditController)
+	payslip.Get(urls.ProfilePath, controllers.ProfileViewController)
+	// HR and approvers pick the organisation they work in
+	payslip.Post(urls.SwitchOrganisationPath, controllers.SwitchOrganisationController)
+	// approval routes
+	approver := pat.New()
+	approver.Post(urls.ApprovePath, controllers.ApprovePayslipController)
+	approver.Post(urls.RejectPath, controllers.RejectPayslipController)
+	approver.Get(urls.ApprovalPath, controllers.ApprovalsPayslipController)
+	approver.Get(urls.ApprovalsPath, controllers.ApprovalsController)
+	approver.NotFoundHandler = http.HandlerFunc(controllers.NotFoundController)
+	payslip.PathPrefix(urls.ApprovalsPath).Handler(withRole(models.RoleApprover, approver))
+	// HR routes: employee master, payroll runs, payroll data imports, salary structures
+	// and arrears
+	hr := pat.New()
+	hr.Get(urls.EmployeesPath, controllers.EmployeesController)
+	hr.Post(urls.PayrollPreviewPath, controllers.PayrollPreviewController)
+	hr.Post(urls.PayrollLockPath, controllers.PayrollLockController)
+	hr.Post(urls.PayrollPublishPath, controllers.PayrollPublishController)
+	hr.Get(urls.PayrollBankExportPath, controllers.PayrollBankExportController)
+	hr.Get(urls.PayrollRunPath, controllers.PayrollRunController)
+	hr.Get(urls.PayrollPath, controllers.PayrollRunsController)
+	hr.Post(urls.PayrollPath, controllers.PayrollRunsController)
+	hr.Post(urls.ImportCommitPath, controllers.ImportCommitController)
+	hr.Get(urls.ImportPath, controllers.ImportController)
+	hr.Get(urls.ImportsPath, controllers.ImportsController)
+	hr.Post(urls.ImportsPath, controllers.ImportsController)
+	hr.Post(urls.StructureAssignPath, controllers.AssignStructureController)
+	hr.Get(urls.StructurePath, controllers.StructureController)
+	hr.Post(urls.StructurePath, controllers.StructureController)
+	hr.Get(urls.StructuresPath, controllers.StructuresController)
+	hr.Get(urls.ExchangeRatesPath, controllers.ExchangeRatesController)
+	hr.Post(urls.ExchangeRatesPath, controllers.ExchangeRatesController)
+	hr.Post(urls.ArrearDeletePath, controllers.DeleteArrearController)
+	hr.Get(urls.ArrearsPath, controllers.ArrearsController)
+	hr.Post(urls.ArrearsPath, controllers.ArrearsController)
+	hr.NotFoundHandler = http.HandlerFunc(controllers.NotFoundController)
+	for _, path := range []string{urls.EmployeesPath, urls.PayrollPath, urls.ImportsPath, urls.StructuresPath, urls.ExchangeRatesPath, urls.ArrearsPath} {
+		payslip.PathPrefix(path).Handler(withRole(models.RoleHR, hr))
+	}
+	// admin routes
+	admin := pat.New()
+	admin.Post(urls.UserRolesPath, controllers.UserRolesController)
+	admin.Get(urls.RolesPath, controllers.RolesController)
+	admin.Get(urls.LoginRejectionsPath, controllers.LoginRejectionsController)
+	admin.Post(urls.RevokeSessionsPath, controllers.RevokeSessionsController)
+	admin.Get(urls.OrganisationPath, controllers.OrganisationController)
+	admin.Post(urls.OrganisationPath, controllers.OrganisationController)
+	admin.Get(urls.OrganisationsPath, controllers.OrganisationsController)
+	admin.NotFoundHandler = http.HandlerFunc(controllers.NotFoundController)
+	payslip.PathPrefix(urls.AdminPath).Handler(withRole(models.RoleAdmin, admin))
+	payslip.Get(urls.HomePath, controllers.PayslipController)
 	payslip.NotFoundHandler = http.HandlerFunc(controllers.NotFoundController)
 	common.PathPrefix(urls.HomePath).Handler(
 		negroni.New(
@@ -37,6 +116,8 @@ func GetRouter() *pat.Router {
 				middlewares.GothLoginMiddleware),
 			negroni.HandlerFunc(
 				middlewares.SetUserMiddleware),
+			negroni.HandlerFunc(
+				middlewares.CSRFMiddleware),
 			negroni.Wrap(payslip),
 		),
 	)
@@ -4,6 +4,8 @@ cat > /go/src/${MS_NAME}/.env << EOF
   bc_intranet_client_id=${BC_CLIENT_ID}
   bc_intranet_client_secret=${BC_CLIENT_SECRET}
   bc_app_key=${BC_APP_KEY}
+  bc_field_keys=${BC_FIELD_KEYS}
+  bc_signing_key=${BC_SIGNING_KEY}
   bc_env=${BC_ENV}
   bc_host=${BC_LOCALHOST}
   bc_mongo_db="${MS_NAME}"
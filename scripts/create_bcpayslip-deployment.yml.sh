@@ -36,6 +36,10 @@ spec:
               value: "${BC_CLIENT_SECRET}"
             - name: bc_app_key
               value: "${BC_APP_KEY}"
+            - name: bc_field_keys
+              value: "${BC_FIELD_KEYS}"
+            - name: bc_signing_key
+              value: "${BC_SIGNING_KEY}"
             - name: bc_env
               value: "${BC_ENV}"
             - name: bc_host
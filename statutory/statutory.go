@@ -0,0 +1,94 @@
+package statutory
+
+import (
+	"math"
+	"time"
+)
+
+// Contributions Employee deductions and employer contributions for one month ...
+type Contributions struct {
+	EmployeePF      float64
+	EmployerPF      float64
+	EmployerPension float64
+	ESIApplicable   bool
+	EmployeeESI     float64
+	EmployerESI     float64
+	PTApplicable    bool
+	ProfessionalTax float64
+}
+
+// ProvidentFund Employee share, employer provident fund share and employer pension
+// share on a monthly basic salary ...
+func ProvidentFund(basic float64) (float64, float64, float64) {
+	wages := math.Min(math.Max(basic, 0), PFWageCeiling)
+	employee := math.Round(wages * PFEmployeeRate)
+	pension := math.Round(wages * PFPensionRate)
+	employer := math.Round(wages*PFEmployerRate) - pension
+	return employee, employer, pension
+}
+
+// ESI Employee and employer state insurance on a monthly gross, only employees
+// earning up to the wage limit are covered ...
+func ESI(gross float64) (float64, float64, bool) {
+	if gross <= 0 || gross > ESIWageLimit {
+		return 0, 0, false
+	}
+	return math.Ceil(gross * ESIEmployeeRate), math.Ceil(gross * ESIEmployerRate), true
+}
+
+// ProfessionalTax Professional tax of a state for a month on the monthly gross, false
+// when the state does not levy it. A half yearly tax is taken on six times the
+// monthly gross in the months it is collected ...
+func ProfessionalTax(state string, gross float64, month time.Time) (float64, bool) {
+	ptState, ok := ptStates[state]
+	if !ok {
+		return 0, false
+	}
+	if len(ptState.CollectIn) > 0 {
+		if !collectedIn(ptState, month.Month()) {
+			return 0, true
+		}
+		gross *= 6
+	}
+	var amount float64
+	for _, slab := range ptState.Slabs {
+		if gross < slab.From {
+			break
+		}
+		amount = slab.Amount
+		if month.Month() == time.February && slab.February > 0 {
+			amount = slab.February
+		}
+	}
+	return amount, true
+}
+
+// AnnualProfessionalTax Professional tax of a state over a year on a monthly gross,
+// the sum of what every month of the year collects ...
+func AnnualProfessionalTax(state string, gross float64) float64 {
+	var total float64
+	for month := time.January; month <= time.December; month++ {
+		amount, _ := ProfessionalTax(state, gross, time.Date(2000, month, 1, 0, 0, 0, 0, time.UTC))
+		total += amount
+	}
+	return total
+}
+
+// collectedIn Whether a half yearly professional tax is deducted in a month ...
+func collectedIn(ptState PTState, month time.Month) bool {
+	for _, collect := range ptState.CollectIn {
+		if collect == month {
+			return true
+		}
+	}
+	return false
+}
+
+// Compute All statutory contributions for a month ...
+func Compute(state string, basic float64, gross float64, month time.Time) Contributions {
+	var c Contributions
+	c.EmployeePF, c.EmployerPF, c.EmployerPension = ProvidentFund(basic)
+	c.EmployeeESI, c.EmployerESI, c.ESIApplicable = ESI(gross)
+	c.ProfessionalTax, c.PTApplicable = ProfessionalTax(state, gross, month)
+	return c
+}
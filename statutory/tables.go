@@ -0,0 +1,86 @@
+package statutory
+
+import "time"
+
+// Provident fund and employee state insurance rates, provident fund is paid on
+// basic salary up to the wage ceiling, insurance on gross up to the wage limit ...
+const (
+	PFWageCeiling   = 15000
+	PFEmployeeRate  = 0.12
+	PFEmployerRate  = 0.12
+	PFPensionRate   = 0.0833
+	ESIWageLimit    = 21000
+	ESIEmployeeRate = 0.0075
+	ESIEmployerRate = 0.0325
+)
+
+// PTSlab Professional tax for a monthly gross salary from From upwards,
+// some states collect a higher amount in February to reach the annual cap ...
+type PTSlab struct {
+	From     float64
+	Amount   float64
+	February float64
+}
+
+// PTState Name and monthly slabs of a state levying professional tax. States that
+// levy it by the half year list the months it is collected in, their slabs are on
+// the income of the half year and the tax is deducted in those months only ...
+type PTState struct {
+	Name      string
+	Slabs     []PTSlab
+	CollectIn []time.Month
+}
+
+// ptStates Professional tax slabs by state code, states not listed do not levy it ...
+var ptStates = map[string]PTState{
+	"AP": {Name: "Andhra Pradesh", Slabs: []PTSlab{
+		{From: 15001, Amount: 150},
+		{From: 20001, Amount: 200},
+	}},
+	"GJ": {Name: "Gujarat", Slabs: []PTSlab{
+		{From: 12000, Amount: 200},
+	}},
+	"KA": {Name: "Karnataka", Slabs: []PTSlab{
+		{From: 25000, Amount: 200, February: 300},
+	}},
+	"MH": {Name: "Maharashtra", Slabs: []PTSlab{
+		{From: 7501, Amount: 175},
+		{From: 10001, Amount: 200, February: 300},
+	}},
+	// the Greater Chennai Corporation slabs revised in 2024, other local bodies in
+	// the state charge less and are not covered
+	"TN": {Name: "Tamil Nadu", CollectIn: []time.Month{time.September, time.March}, Slabs: []PTSlab{
+		{From: 21001, Amount: 180},
+		{From: 30001, Amount: 430},
+		{From: 45001, Amount: 930},
+		{From: 60001, Amount: 1025},
+		{From: 75001, Amount: 1250},
+	}},
+	"TS": {Name: "Telangana", Slabs: []PTSlab{
+		{From: 15001, Amount: 150},
+		{From: 20001, Amount: 200},
+	}},
+	"WB": {Name: "West Bengal", Slabs: []PTSlab{
+		{From: 10001, Amount: 110},
+		{From: 15001, Amount: 130},
+		{From: 25001, Amount: 150},
+		{From: 40001, Amount: 200},
+	}},
+}
+
+// States Codes and names of the states, including ones without professional tax ...
+var States = []struct {
+	Code string
+	Name string
+}{
+	{"AP", "Andhra Pradesh"},
+	{"DL", "Delhi"},
+	{"GJ", "Gujarat"},
+	{"HR", "Haryana"},
+	{"KA", "Karnataka"},
+	{"MH", "Maharashtra"},
+	{"TN", "Tamil Nadu"},
+	{"TS", "Telangana"},
+	{"UP", "Uttar Pradesh"},
+	{"WB", "West Bengal"},
+}
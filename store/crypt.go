@@ -0,0 +1,313 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"bcpayslip/models"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// fieldPrefix Marks an encrypted value, it is followed by the key id and the
+// base64 nonce and ciphertext, enc:<keyid>:<data> ...
+const fieldPrefix = "enc:"
+
+// ErrNoFieldKey Sensitive fields can not be saved without an encryption key ...
+var ErrNoFieldKey = errors.New("bc_field_keys is not set, sensitive fields can not be encrypted")
+
+// encryptedFields Paths of the encrypted fields in each collection, the last part
+// of a path is the field name the ciphertext is bound to ...
+var encryptedFields = map[string][]string{
+	"User":     {"accesstoken"},
+	"Employee": {"accountno", "ifsccode"},
+	"Payslip":  {"accountno", "ifsccode", "requestor.accesstoken", "approver.accesstoken"},
+}
+
+// FieldCipher AES-GCM encryption of single field values. New values are
+// encrypted with the current key, older keys are kept to read values encrypted
+// before a rotation ...
+type FieldCipher struct {
+	KeyID string
+	aeads map[string]cipher.AEAD
+}
+
+var (
+	fieldCipher     *FieldCipher
+	fieldCipherErr  error
+	fieldCipherOnce sync.Once
+)
+
+// NewFieldCipher Cipher for keys in the form id:base64key separated by commas,
+// the first key is the current one. Keys are 32 random bytes (AES-256) ...
+func NewFieldCipher(config string) (*FieldCipher, error) {
+	c := &FieldCipher{aeads: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.New("field keys are written as id:base64key")
+		}
+		id := parts[0]
+		if _, ok := c.aeads[id]; ok {
+			return nil, fmt.Errorf("field key %s is listed twice", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("field key %s is not 32 bytes of base64", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.aeads[id] = aead
+		if c.KeyID == "" {
+			c.KeyID = id
+		}
+	}
+	if c.KeyID == "" {
+		return nil, ErrNoFieldKey
+	}
+	return c, nil
+}
+
+// Seal Encrypt the value of a field with the current key, the field name is
+// authenticated so a value can not be moved to another field ...
+func (c *FieldCipher) Seal(field string, value string) (string, error) {
+	if value == "" || strings.HasPrefix(value, fieldPrefix) {
+		return value, nil
+	}
+	aead := c.aeads[c.KeyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(field))
+	return fieldPrefix + c.KeyID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open Decrypt the value of a field, values saved before encryption was turned
+// on are returned as they are ...
+func (c *FieldCipher) Open(field string, value string) (string, error) {
+	if !strings.HasPrefix(value, fieldPrefix) {
+		return value, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, fieldPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("%s is not a valid encrypted value", field)
+	}
+	aead, ok := c.aeads[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%s is encrypted with unknown key %s", field, parts[0])
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("%s is not a valid encrypted value", field)
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("%s could not be decrypted with key %s", field, parts[0])
+	}
+	return string(plain), nil
+}
+
+// Current Whether a value needs no re-encryption, it is empty or encrypted with
+// the current key ...
+func (c *FieldCipher) Current(value string) bool {
+	return value == "" || strings.HasPrefix(value, fieldPrefix+c.KeyID+":")
+}
+
+// getFieldCipher The cipher of the keys in bc_field_keys. Without keys fields are
+// saved in plain text in development and refused everywhere else ...
+func getFieldCipher() (*FieldCipher, error) {
+	fieldCipherOnce.Do(func() {
+		fieldCipher, fieldCipherErr = NewFieldCipher(os.Getenv("bc_field_keys"))
+		if fieldCipherErr == ErrNoFieldKey && os.Getenv("bc_env") == "development" {
+			log.Println("store: bc_field_keys is not set, sensitive fields are saved in plain text")
+		}
+	})
+	return fieldCipher, fieldCipherErr
+}
+
+// sealField ...
+func sealField(field string, value string) (string, error) {
+	c, err := getFieldCipher()
+	if err == ErrNoFieldKey && os.Getenv("bc_env") == "development" {
+		return value, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return c.Seal(field, value)
+}
+
+// openField ...
+func openField(field string, value string) (string, error) {
+	if !strings.HasPrefix(value, fieldPrefix) {
+		return value, nil
+	}
+	c, err := getFieldCipher()
+	if err != nil {
+		return "", err
+	}
+	return c.Open(field, value)
+}
+
+// sealUser ...
+func sealUser(user *models.User) (err error) {
+	user.AccessToken, err = sealField("accesstoken", user.AccessToken)
+	return err
+}
+
+// openUser ...
+func openUser(user *models.User) (err error) {
+	user.AccessToken, err = openField("accesstoken", user.AccessToken)
+	return err
+}
+
+// sealEmployee ...
+func sealEmployee(employee *models.Employee) (err error) {
+	if employee.AccountNo, err = sealField("accountno", employee.AccountNo); err != nil {
+		return err
+	}
+	employee.IFSCCode, err = sealField("ifsccode", employee.IFSCCode)
+	return err
+}
+
+// openEmployee ...
+func openEmployee(employee *models.Employee) (err error) {
+	if employee.AccountNo, err = openField("accountno", employee.AccountNo); err != nil {
+		return err
+	}
+	employee.IFSCCode, err = openField("ifsccode", employee.IFSCCode)
+	return err
+}
+
+// openEmployees ...
+func openEmployees(employees []models.Employee) error {
+	for i := range employees {
+		if err := openEmployee(&employees[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sealPayslip ...
+func sealPayslip(payslip *models.Payslip) (err error) {
+	if payslip.AccountNo, err = sealField("accountno", payslip.AccountNo); err != nil {
+		return err
+	}
+	if payslip.IFSCCode, err = sealField("ifsccode", payslip.IFSCCode); err != nil {
+		return err
+	}
+	if err = sealUser(&payslip.Requestor); err != nil {
+		return err
+	}
+	return sealUser(&payslip.Approver)
+}
+
+// openPayslip ...
+func openPayslip(payslip *models.Payslip) (err error) {
+	if payslip.AccountNo, err = openField("accountno", payslip.AccountNo); err != nil {
+		return err
+	}
+	if payslip.IFSCCode, err = openField("ifsccode", payslip.IFSCCode); err != nil {
+		return err
+	}
+	if err = openUser(&payslip.Requestor); err != nil {
+		return err
+	}
+	return openUser(&payslip.Approver)
+}
+
+// openPayslips ...
+func openPayslips(payslips []models.Payslip) error {
+	for i := range payslips {
+		if err := openPayslip(&payslips[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldAt String at a dotted path of a document ...
+func fieldAt(doc bson.M, path string) string {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		nested, ok := doc[part].(bson.M)
+		if !ok {
+			return ""
+		}
+		doc = nested
+	}
+	value, _ := doc[parts[len(parts)-1]].(string)
+	return value
+}
+
+// Reencrypt Encrypt every sensitive field that is in plain text or encrypted with
+// an older key with the current key, so the older key can be retired. The number
+// of updated documents of each collection is returned ...
+func Reencrypt() (map[string]int, error) {
+	updated := make(map[string]int)
+	c, err := getFieldCipher()
+	if err != nil {
+		return updated, err
+	}
+	pks := map[string]string{"User": "UserID", "Employee": "userid", "Payslip": "uuid"}
+	for collection, paths := range encryptedFields {
+		session := GetSession(collection, pks[collection])
+		session = session.Copy()
+		coll := session.DB(os.Getenv("bc_mongo_db")).C(collection)
+		iter := coll.Find(nil).Iter()
+		var doc bson.M
+		for iter.Next(&doc) {
+			fields := bson.M{}
+			for _, path := range paths {
+				value := fieldAt(doc, path)
+				if c.Current(value) {
+					continue
+				}
+				name := path[strings.LastIndex(path, ".")+1:]
+				plain, err := c.Open(name, value)
+				if err != nil {
+					session.Close()
+					return updated, err
+				}
+				if fields[path], err = c.Seal(name, plain); err != nil {
+					session.Close()
+					return updated, err
+				}
+			}
+			if len(fields) > 0 {
+				if err = coll.UpdateId(doc["_id"], bson.M{"$set": fields}); err != nil {
+					session.Close()
+					return updated, err
+				}
+				updated[collection]++
+			}
+			doc = nil
+		}
+		err = iter.Close()
+		session.Close()
+		if err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
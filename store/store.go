@@ -1,31 +1,54 @@
 package store
 
 import (
+	"encoding/base64"
+	"errors"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 
+	"bcpayslip/currency"
 	"bcpayslip/helpers"
 	"bcpayslip/models"
+	"bcpayslip/money"
 
 	_ "github.com/joho/godotenv/autoload"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
-// GetSession Dial to database and Return mgo session ...
+// root The session dialled on first use, every store call copies it so calls share
+// its connection pool instead of dialling on their own ...
+var (
+	root     *mgo.Session
+	dialOnce sync.Once
+	indexes  sync.Map
+)
+
+// ErrPayslipSigned The signed PDF of a payslip is kept already ...
+var ErrPayslipSigned = errors.New("the payslip is signed already")
+
+// GetSession Return the mgo session dialled once for the app, callers Copy it and
+// Close the copy. The index of a collection is ensured on its first use ...
 func GetSession(collection string, pk string) *mgo.Session {
-	var session *mgo.Session
-	var err error
-	if os.Getenv("bc_env") == "development" {
-		session, err = mgo.Dial("127.0.0.1")
-	} else {
-		session, err = mgo.Dial(os.Getenv("MONGO_URI"))
+	dialOnce.Do(func() {
+		var err error
+		if os.Getenv("bc_env") == "development" {
+			root, err = mgo.Dial("127.0.0.1")
+		} else {
+			root, err = mgo.Dial(os.Getenv("MONGO_URI"))
+		}
+		if err != nil {
+			panic(err)
+		}
+		root.SetMode(mgo.Monotonic, true)
+	})
+	if _, ensured := indexes.LoadOrStore(collection+"."+pk, true); !ensured {
+		EnsureIndex(collection, pk, root)
 	}
-	if err != nil {
-		panic(err)
-	}
-	session.SetMode(mgo.Monotonic, true)
-	EnsureIndex(collection, pk, session)
-	return session
+	return root
 }
 
 // EnsureIndex Ensure an index on the collection, why? ...
@@ -54,6 +77,9 @@ func GetUser(userID string) (models.User, error) {
 	c := session.DB(os.Getenv("bc_mongo_db")).C("User")
 	var user models.User
 	err := c.Find(bson.M{"userid": userID}).One(&user)
+	if err == nil {
+		err = openUser(&user)
+	}
 	return user, err
 }
 
@@ -63,16 +89,22 @@ func SaveUser(userID string, firstName string, lastName string, email string, ac
 	session = session.Copy()
 	defer session.Close()
 	c := session.DB(os.Getenv("bc_mongo_db")).C("User")
-	_, err := GetUser(userID)
+	accessToken, err := sealField("accesstoken", accessToken)
+	if err != nil {
+		return err
+	}
+	_, err = GetUser(userID)
 	if err == nil {
-		err = c.Update(
-			bson.M{"userid": userID},
-			bson.M{"$set": bson.M{
-				"userid": userID, "firstname": firstName,
-				"lastname": lastName, "email": email,
-				"accesstoken": accessToken, "avatar": helpers.ImageToBase64(avatar),
-			}},
-		)
+		fields := bson.M{
+			"userid": userID, "firstname": firstName,
+			"lastname": lastName, "email": email,
+			"accesstoken": accessToken,
+		}
+		// keep the old picture when the provider logged in with has none
+		if image := helpers.ImageToBase64(avatar); image != "" {
+			fields["avatar"] = image
+		}
+		err = c.Update(bson.M{"userid": userID}, bson.M{"$set": fields})
 	} else {
 		var user models.User
 		user.UserID = userID
@@ -85,3 +117,840 @@ func SaveUser(userID string, firstName string, lastName string, email string, ac
 	}
 	return err
 }
+
+// SavePayslip Create or update payslip data keyed by its UUID ...
+func SavePayslip(payslip *models.Payslip) error {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	stored := *payslip
+	if err := sealPayslip(&stored); err != nil {
+		return err
+	}
+	_, err := c.Upsert(bson.M{"uuid": payslip.UUID}, stored)
+	return err
+}
+
+// GetPayslip get payslip data by UUID ...
+func GetPayslip(uuid string) (models.Payslip, error) {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	var payslip models.Payslip
+	err := c.Find(bson.M{"uuid": uuid}).One(&payslip)
+	if err == nil {
+		err = openPayslip(&payslip)
+	}
+	return payslip, err
+}
+
+// GetPayslips list payslips of a requestor, latest first, one page at a time.
+// Pages start at 1, the total number of payslips is returned for pagination ...
+func GetPayslips(requestorID string, page int, perPage int) ([]models.Payslip, int, error) {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	var payslips []models.Payslip
+	if page < 1 {
+		page = 1
+	}
+	query := c.Find(bson.M{"requestor.userid": requestorID})
+	total, err := query.Count()
+	if err != nil {
+		return payslips, 0, err
+	}
+	err = query.Sort("-requestedon").Skip((page - 1) * perPage).Limit(perPage).All(&payslips)
+	if err == nil {
+		err = openPayslips(payslips)
+	}
+	return payslips, total, err
+}
+
+// inOrg Query for the records of an organisation, records saved before there were
+// several organisations have no orgid and belong to the default one ...
+func inOrg(orgID string) interface{} {
+	if orgID == models.DefaultOrgID {
+		return bson.M{"$in": []interface{}{orgID, "", nil}}
+	}
+	return orgID
+}
+
+// GetPayslipsByStatus list payslips of an organisation in a workflow state, oldest
+// request first ...
+func GetPayslipsByStatus(orgID string, status models.PayslipStatus) ([]models.Payslip, error) {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	var payslips []models.Payslip
+	err := c.Find(bson.M{"orgid": inOrg(orgID), "status": status}).Sort("requestedon").All(&payslips)
+	if err == nil {
+		err = openPayslips(payslips)
+	}
+	return payslips, err
+}
+
+// UpdatePayslipStatus Save a payslip only if it is still in the given state,
+// mgo.ErrNotFound is returned when someone else moved it first ...
+func UpdatePayslipStatus(payslip *models.Payslip, from models.PayslipStatus) error {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	stored := *payslip
+	if err := sealPayslip(&stored); err != nil {
+		return err
+	}
+	return c.Update(bson.M{"uuid": payslip.UUID, "status": from}, stored)
+}
+
+// SavePayslipAccess Record who downloaded a payslip ...
+func SavePayslipAccess(access models.PayslipAccess) error {
+	session := GetSession("PayslipAccess", "accessid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("PayslipAccess")
+	return c.Insert(access)
+}
+
+// GetUserByEmail get user data by email, ignoring case ...
+func GetUserByEmail(email string) (models.User, error) {
+	session := GetSession("User", "UserID")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("User")
+	var user models.User
+	if email == "" {
+		return user, mgo.ErrNotFound
+	}
+	query := bson.M{"email": bson.RegEx{Pattern: "^" + regexp.QuoteMeta(email) + "$", Options: "i"}}
+	err := c.Find(query).One(&user)
+	if err == nil {
+		err = openUser(&user)
+	}
+	return user, err
+}
+
+// SaveSalaryStructure Create or update a salary structure keyed by its id ...
+func SaveSalaryStructure(structure *models.SalaryStructure) error {
+	session := GetSession("SalaryStructure", "structureid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("SalaryStructure")
+	_, err := c.Upsert(bson.M{"structureid": structure.StructureID}, structure)
+	return err
+}
+
+// GetSalaryStructure get salary structure by id ...
+func GetSalaryStructure(structureID string) (models.SalaryStructure, error) {
+	session := GetSession("SalaryStructure", "structureid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("SalaryStructure")
+	var structure models.SalaryStructure
+	err := c.Find(bson.M{"structureid": structureID}).One(&structure)
+	return structure, err
+}
+
+// GetSalaryStructures list the salary structures of an organisation by name ...
+func GetSalaryStructures(orgID string) ([]models.SalaryStructure, error) {
+	session := GetSession("SalaryStructure", "structureid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("SalaryStructure")
+	var structures []models.SalaryStructure
+	err := c.Find(bson.M{"orgid": inOrg(orgID)}).Sort("name").All(&structures)
+	return structures, err
+}
+
+// gradeKey Key of the assignment of a grade, grades are named by each organisation
+// and keep their old key in the default one ...
+func gradeKey(orgID string, grade string) string {
+	if models.OrgIDOr(orgID) == models.DefaultOrgID {
+		return "grade:" + grade
+	}
+	return "grade:" + orgID + ":" + grade
+}
+
+// AssignSalaryStructure Assign a structure to a user or a grade of an organisation,
+// an empty structure id removes the assignment ...
+func AssignSalaryStructure(assignment models.StructureAssignment) error {
+	session := GetSession("StructureAssignment", "key")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("StructureAssignment")
+	if assignment.UserID != "" {
+		assignment.Key = "user:" + assignment.UserID
+	} else {
+		assignment.Key = gradeKey(assignment.OrgID, assignment.Grade)
+	}
+	if assignment.StructureID == "" {
+		err := c.Remove(bson.M{"key": assignment.Key})
+		if err == mgo.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	_, err := c.Upsert(bson.M{"key": assignment.Key}, assignment)
+	return err
+}
+
+// GetStructureAssignments list the structure assignments of an organisation ...
+func GetStructureAssignments(orgID string) ([]models.StructureAssignment, error) {
+	session := GetSession("StructureAssignment", "key")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("StructureAssignment")
+	var assignments []models.StructureAssignment
+	err := c.Find(bson.M{"orgid": inOrg(orgID)}).Sort("key").All(&assignments)
+	return assignments, err
+}
+
+// GetSalaryStructureFor Find the structure assigned to a user, falling back to
+// the one assigned to their grade in their organisation, mgo.ErrNotFound if
+// neither exists ...
+func GetSalaryStructureFor(userID string, orgID string, grade string) (models.SalaryStructure, error) {
+	session := GetSession("StructureAssignment", "key")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("StructureAssignment")
+	var assignment models.StructureAssignment
+	err := c.Find(bson.M{"key": "user:" + userID}).One(&assignment)
+	if err == mgo.ErrNotFound && grade != "" {
+		err = c.Find(bson.M{"key": gradeKey(orgID, grade)}).One(&assignment)
+	}
+	if err != nil {
+		return models.SalaryStructure{}, err
+	}
+	return GetSalaryStructure(assignment.StructureID)
+}
+
+// GetUsers list all registered users by name ...
+func GetUsers() ([]models.User, error) {
+	session := GetSession("User", "UserID")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("User")
+	var users []models.User
+	err := c.Find(nil).Select(bson.M{"avatar": 0, "accesstoken": 0}).Sort("firstname", "lastname").All(&users)
+	return users, err
+}
+
+// SetUserRoles Replace the roles granted to a user ...
+func SetUserRoles(userID string, roles []string) error {
+	session := GetSession("User", "UserID")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("User")
+	return c.Update(bson.M{"userid": userID}, bson.M{"$set": bson.M{"roles": roles}})
+}
+
+// SaveEmployee Create or update the employee record of a user ...
+func SaveEmployee(employee *models.Employee) error {
+	session := GetSession("Employee", "userid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Employee")
+	stored := *employee
+	if err := sealEmployee(&stored); err != nil {
+		return err
+	}
+	_, err := c.Upsert(bson.M{"userid": employee.UserID}, stored)
+	return err
+}
+
+// GetEmployee get the employee record of a user ...
+func GetEmployee(userID string) (models.Employee, error) {
+	session := GetSession("Employee", "userid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Employee")
+	var employee models.Employee
+	err := c.Find(bson.M{"userid": userID}).One(&employee)
+	if err == nil {
+		err = openEmployee(&employee)
+	}
+	return employee, err
+}
+
+// GetEmployees list the employee records of an organisation by employee number ...
+func GetEmployees(orgID string) ([]models.Employee, error) {
+	session := GetSession("Employee", "userid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Employee")
+	var employees []models.Employee
+	err := c.Find(bson.M{"orgid": inOrg(orgID)}).Sort("employeeno").All(&employees)
+	if err == nil {
+		err = openEmployees(employees)
+	}
+	return employees, err
+}
+
+// GetEmployeeOrgIDs The organisation of every user with an employee record ...
+func GetEmployeeOrgIDs() (map[string]string, error) {
+	session := GetSession("Employee", "userid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Employee")
+	var employees []models.Employee
+	err := c.Find(nil).Select(bson.M{"userid": 1, "orgid": 1}).All(&employees)
+	orgIDs := make(map[string]string)
+	for _, employee := range employees {
+		orgIDs[employee.UserID] = models.OrgIDOr(employee.OrgID)
+	}
+	return orgIDs, err
+}
+
+// GetActiveEmployees list the employee records of an organisation that are paid
+// in its payroll runs ...
+func GetActiveEmployees(orgID string) ([]models.Employee, error) {
+	session := GetSession("Employee", "userid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Employee")
+	var employees []models.Employee
+	err := c.Find(bson.M{"orgid": inOrg(orgID), "active": true}).Sort("employeeno").All(&employees)
+	if err == nil {
+		err = openEmployees(employees)
+	}
+	return employees, err
+}
+
+// SavePayrollRun Create or update a payroll run, one run per period of each
+// organisation ...
+func SavePayrollRun(run *models.PayrollRun) error {
+	session := GetSession("PayrollRun", "key")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("PayrollRun")
+	run.Key = models.OrgIDOr(run.OrgID) + ":" + run.Period
+	_, err := c.Upsert(bson.M{"runid": run.RunID}, run)
+	return err
+}
+
+// GetPayrollRun get payroll run by id ...
+func GetPayrollRun(runID string) (models.PayrollRun, error) {
+	session := GetSession("PayrollRun", "key")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("PayrollRun")
+	var run models.PayrollRun
+	err := c.Find(bson.M{"runid": runID}).One(&run)
+	return run, err
+}
+
+// GetPayrollRuns list the payroll runs of an organisation, latest period first,
+// without their results ...
+func GetPayrollRuns(orgID string) ([]models.PayrollRun, error) {
+	session := GetSession("PayrollRun", "key")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("PayrollRun")
+	var runs []models.PayrollRun
+	err := c.Find(bson.M{"orgid": inOrg(orgID)}).Select(bson.M{"results": 0}).Sort("-period").All(&runs)
+	return runs, err
+}
+
+// GetPayslipsOfRun list payslips produced by a payroll run ...
+func GetPayslipsOfRun(runID string) ([]models.Payslip, error) {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	var payslips []models.Payslip
+	err := c.Find(bson.M{"runid": runID}).Sort("employeeno").All(&payslips)
+	if err == nil {
+		err = openPayslips(payslips)
+	}
+	return payslips, err
+}
+
+// DeleteDraftPayslipsOfRun Remove the draft payslips of a payroll run before it is previewed again ...
+func DeleteDraftPayslipsOfRun(runID string) error {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	_, err := c.RemoveAll(bson.M{"runid": runID, "status": models.PayslipDraft})
+	return err
+}
+
+// inMonth Query for dates in a calendar month, payslips requested through the form
+// keep the day that was picked ...
+func inMonth(month time.Time) bson.M {
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return bson.M{"$gte": first, "$lt": first.AddDate(0, 1, 0)}
+}
+
+// GetPayslipFor get the latest payslip of a user for a month, if there is one ...
+func GetPayslipFor(userID string, month time.Time) (models.Payslip, error) {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	var payslip models.Payslip
+	err := c.Find(bson.M{"requestor.userid": userID, "month": inMonth(month)}).Sort("-requestedon").One(&payslip)
+	if err == nil {
+		err = openPayslip(&payslip)
+	}
+	return payslip, err
+}
+
+// GetApprovedPayslipsFor list the approved and issued payslips of an organisation
+// for a month ...
+func GetApprovedPayslipsFor(orgID string, month time.Time) ([]models.Payslip, error) {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	var payslips []models.Payslip
+	query := bson.M{
+		"orgid":  inOrg(orgID),
+		"month":  inMonth(month),
+		"status": bson.M{"$in": []models.PayslipStatus{models.PayslipApproved, models.PayslipIssued}},
+	}
+	err := c.Find(query).Sort("employeeno").All(&payslips)
+	if err == nil {
+		err = openPayslips(payslips)
+	}
+	return payslips, err
+}
+
+// GetApprovedTotalsFor count and sum the net pay of the approved and issued rupee
+// payslips of an organisation for a month, added up by the database apart from the
+// payslips themselves so a bank export can be checked against them ...
+func GetApprovedTotalsFor(orgID string, month time.Time) (int, money.Amount, error) {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	match := bson.M{
+		"orgid":    inOrg(orgID),
+		"month":    inMonth(month),
+		"status":   bson.M{"$in": []models.PayslipStatus{models.PayslipApproved, models.PayslipIssued}},
+		"currency": bson.M{"$in": []interface{}{currency.Base, "", nil}},
+	}
+	var totals struct {
+		Count int     `bson:"count"`
+		Net   float64 `bson:"net"`
+	}
+	err := c.Pipe([]bson.M{
+		{"$match": match},
+		{"$group": bson.M{"_id": nil, "count": bson.M{"$sum": 1}, "net": bson.M{"$sum": "$amountreceivedbank"}}},
+	}).One(&totals)
+	if err == mgo.ErrNotFound {
+		err = nil
+	}
+	return totals.Count, money.FromFloat(totals.Net), err
+}
+
+// SavePayrollImport Create or update a payroll data import ...
+func SavePayrollImport(payrollImport *models.PayrollImport) error {
+	session := GetSession("PayrollImport", "importid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("PayrollImport")
+	_, err := c.Upsert(bson.M{"importid": payrollImport.ImportID}, payrollImport)
+	return err
+}
+
+// ClaimPayrollImport Mark a payroll data import committed only if it is not yet,
+// mgo.ErrNotFound is returned when someone else committed it first ...
+func ClaimPayrollImport(payrollImport *models.PayrollImport) error {
+	session := GetSession("PayrollImport", "importid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("PayrollImport")
+	return c.Update(bson.M{
+		"importid": payrollImport.ImportID,
+		"$or":      []bson.M{{"committedon": bson.M{"$exists": false}}, {"committedon": time.Time{}}},
+	}, bson.M{"$set": bson.M{"committedby": payrollImport.CommittedBy, "committedon": payrollImport.CommittedOn}})
+}
+
+// GetPayrollImport get payroll data import by id ...
+func GetPayrollImport(importID string) (models.PayrollImport, error) {
+	session := GetSession("PayrollImport", "importid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("PayrollImport")
+	var payrollImport models.PayrollImport
+	err := c.Find(bson.M{"importid": importID}).One(&payrollImport)
+	return payrollImport, err
+}
+
+// GetPayrollImports list recent payroll data imports of an organisation without
+// their rows ...
+func GetPayrollImports(orgID string, limit int) ([]models.PayrollImport, error) {
+	session := GetSession("PayrollImport", "importid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("PayrollImport")
+	var imports []models.PayrollImport
+	err := c.Find(bson.M{"orgid": inOrg(orgID)}).Select(bson.M{"rows": 0}).Sort("-createdon").Limit(limit).All(&imports)
+	return imports, err
+}
+
+// SaveLoginRejection Record a login that was turned away ...
+func SaveLoginRejection(rejection *models.LoginRejection) error {
+	session := GetSession("LoginRejection", "rejectionid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("LoginRejection")
+	return c.Insert(rejection)
+}
+
+// GetLoginRejections list the latest logins that were turned away ...
+func GetLoginRejections(limit int) ([]models.LoginRejection, error) {
+	session := GetSession("LoginRejection", "rejectionid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("LoginRejection")
+	var rejections []models.LoginRejection
+	err := c.Find(nil).Sort("-attemptedon").Limit(limit).All(&rejections)
+	return rejections, err
+}
+
+// GetUserSession get a login session by id ...
+func GetUserSession(sessionID string) (models.UserSession, error) {
+	session := GetSession("UserSession", "sessionid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("UserSession")
+	var userSession models.UserSession
+	err := c.Find(bson.M{"sessionid": sessionID}).One(&userSession)
+	return userSession, err
+}
+
+// SaveUserSession Create a login session or update its values, when it was created,
+// where from and when it expires are only set for new sessions ...
+func SaveUserSession(userSession *models.UserSession) error {
+	session := GetSession("UserSession", "sessionid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("UserSession")
+	_, err := c.Upsert(bson.M{"sessionid": userSession.SessionID}, bson.M{
+		"$set": bson.M{
+			"userid":     userSession.UserID,
+			"values":     userSession.Values,
+			"lastseenon": userSession.LastSeenOn,
+		},
+		"$setOnInsert": bson.M{
+			"remoteaddr": userSession.RemoteAddr,
+			"useragent":  userSession.UserAgent,
+			"createdon":  userSession.CreatedOn,
+			"expireson":  userSession.ExpiresOn,
+		},
+	})
+	return err
+}
+
+// TouchUserSession Record activity on a login session ...
+func TouchUserSession(sessionID string, seenOn time.Time) error {
+	session := GetSession("UserSession", "sessionid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("UserSession")
+	return c.Update(bson.M{"sessionid": sessionID}, bson.M{"$set": bson.M{"lastseenon": seenOn}})
+}
+
+// GetUserSessions list the login sessions of a user, latest first ...
+func GetUserSessions(userID string) ([]models.UserSession, error) {
+	session := GetSession("UserSession", "sessionid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("UserSession")
+	var userSessions []models.UserSession
+	err := c.Find(bson.M{"userid": userID}).Select(bson.M{"values": 0}).Sort("-lastseenon").All(&userSessions)
+	return userSessions, err
+}
+
+// DeleteUserSession End a login session ...
+func DeleteUserSession(sessionID string) error {
+	session := GetSession("UserSession", "sessionid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("UserSession")
+	_, err := c.RemoveAll(bson.M{"sessionid": sessionID})
+	return err
+}
+
+// DeleteUserSessions End every login session of a user ...
+func DeleteUserSessions(userID string) (int, error) {
+	session := GetSession("UserSession", "sessionid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("UserSession")
+	info, err := c.RemoveAll(bson.M{"userid": userID})
+	if err != nil {
+		return 0, err
+	}
+	return info.Removed, nil
+}
+
+// DeleteExpiredUserSessions Remove login sessions past their expiry ...
+func DeleteExpiredUserSessions(now time.Time) error {
+	session := GetSession("UserSession", "sessionid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("UserSession")
+	_, err := c.RemoveAll(bson.M{"expireson": bson.M{"$lt": now}})
+	return err
+}
+
+// GetOrganisation get an organisation, the default organisation exists before it
+// is first saved ...
+func GetOrganisation(orgID string) (models.Organisation, error) {
+	session := GetSession("Organisation", "orgid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Organisation")
+	var organisation models.Organisation
+	err := c.Find(bson.M{"orgid": orgID}).One(&organisation)
+	if err == mgo.ErrNotFound && orgID == models.DefaultOrgID {
+		return models.Organisation{OrgID: orgID, Name: "Beautiful Code"}, nil
+	}
+	return organisation, err
+}
+
+// GetOrganisations list all organisations by name, the default one included ...
+func GetOrganisations() ([]models.Organisation, error) {
+	session := GetSession("Organisation", "orgid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Organisation")
+	var organisations []models.Organisation
+	if err := c.Find(nil).Sort("name").All(&organisations); err != nil {
+		return organisations, err
+	}
+	for _, organisation := range organisations {
+		if organisation.OrgID == models.DefaultOrgID {
+			return organisations, nil
+		}
+	}
+	organisation, err := GetOrganisation(models.DefaultOrgID)
+	return append([]models.Organisation{organisation}, organisations...), err
+}
+
+// SaveOrganisation Create or update the settings of an organisation ...
+func SaveOrganisation(organisation *models.Organisation) error {
+	session := GetSession("Organisation", "orgid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Organisation")
+	_, err := c.Upsert(bson.M{"orgid": organisation.OrgID}, organisation)
+	return err
+}
+
+// SaveExchangeRate Create or update the rate of a currency for a month ...
+func SaveExchangeRate(rate *models.ExchangeRate) error {
+	session := GetSession("ExchangeRate", "key")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("ExchangeRate")
+	rate.Key = rate.Currency + ":" + rate.Period
+	_, err := c.Upsert(bson.M{"key": rate.Key}, rate)
+	return err
+}
+
+// GetExchangeRate get the rate of a currency in force for a month, the latest one
+// set up to that month ...
+func GetExchangeRate(code string, month time.Time) (models.ExchangeRate, error) {
+	session := GetSession("ExchangeRate", "key")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("ExchangeRate")
+	var rate models.ExchangeRate
+	query := bson.M{"currency": code, "month": bson.M{"$lt": inMonth(month)["$lt"]}}
+	err := c.Find(query).Sort("-month").One(&rate)
+	return rate, err
+}
+
+// GetExchangeRates list the rates of all currencies, latest month first ...
+func GetExchangeRates() ([]models.ExchangeRate, error) {
+	session := GetSession("ExchangeRate", "key")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("ExchangeRate")
+	var rates []models.ExchangeRate
+	err := c.Find(nil).Sort("-month", "currency").All(&rates)
+	return rates, err
+}
+
+// GetPayslipByVerificationCode get the payslip a verification code was printed on ...
+func GetPayslipByVerificationCode(code string) (models.Payslip, error) {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	var payslip models.Payslip
+	if code == "" {
+		return payslip, mgo.ErrNotFound
+	}
+	err := c.Find(bson.M{"verificationcode": code}).One(&payslip)
+	if err == nil {
+		err = openPayslip(&payslip)
+	}
+	return payslip, err
+}
+
+// SetPayslipSignature Save the verification code and signature of a generated
+// payslip PDF, whatever state the payslip is in ...
+func SetPayslipSignature(payslip *models.Payslip) error {
+	session := GetSession("Payslip", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Payslip")
+	return c.Update(bson.M{"uuid": payslip.UUID}, bson.M{"$set": bson.M{
+		"verificationcode": payslip.VerificationCode,
+		"signaturekey":     payslip.SignatureKey,
+		"signature":        payslip.Signature,
+		"signedon":         payslip.SignedOn,
+	}})
+}
+
+// SavePayslipPDF Keep the bytes a payslip PDF was signed with and its signature.
+// A payslip is signed once, when its signed PDF is kept already the PDF is left
+// as it is and ErrPayslipSigned is returned ...
+func SavePayslipPDF(payslip *models.Payslip, pdf []byte) error {
+	session := GetSession("PayslipPDF", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("PayslipPDF")
+	sealed, err := sealField("pdf", base64.StdEncoding.EncodeToString(pdf))
+	if err != nil {
+		return err
+	}
+	err = c.Insert(models.PayslipPDF{
+		UUID:             payslip.UUID,
+		OrgID:            payslip.OrgID,
+		PDF:              sealed,
+		VerificationCode: payslip.VerificationCode,
+		SignatureKey:     payslip.SignatureKey,
+		Signature:        payslip.Signature,
+		SignedOn:         payslip.SignedOn,
+	})
+	if mgo.IsDup(err) {
+		return ErrPayslipSigned
+	}
+	return err
+}
+
+// GetPayslipPDF get the bytes a payslip PDF was signed with and its signature ...
+func GetPayslipPDF(uuid string) (models.PayslipPDF, []byte, error) {
+	session := GetSession("PayslipPDF", "uuid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("PayslipPDF")
+	var stored models.PayslipPDF
+	if err := c.Find(bson.M{"uuid": uuid}).One(&stored); err != nil {
+		return stored, nil, err
+	}
+	opened, err := openField("pdf", stored.PDF)
+	if err != nil {
+		return stored, nil, err
+	}
+	pdf, err := base64.StdEncoding.DecodeString(opened)
+	return stored, pdf, err
+}
+
+// orgCollections Collections whose records belong to an organisation ...
+var orgCollections = map[string]string{
+	"Employee":            "userid",
+	"Payslip":             "uuid",
+	"PayrollRun":          "key",
+	"PayrollImport":       "importid",
+	"SalaryStructure":     "structureid",
+	"StructureAssignment": "key",
+}
+
+// MigrateOrganisations Assign records saved before there were several
+// organisations to the default one, and key payroll runs by organisation and
+// period instead of period alone so every organisation can run each month ...
+func MigrateOrganisations() (map[string]int, error) {
+	updated := make(map[string]int)
+	unassigned := bson.M{"orgid": bson.M{"$in": []interface{}{"", nil}}}
+	for collection, pk := range orgCollections {
+		session := GetSession(collection, pk)
+		session = session.Copy()
+		c := session.DB(os.Getenv("bc_mongo_db")).C(collection)
+		info, err := c.UpdateAll(unassigned, bson.M{"$set": bson.M{"orgid": models.DefaultOrgID}})
+		session.Close()
+		if err != nil {
+			return updated, err
+		}
+		updated[collection] = info.Updated
+	}
+	session := GetSession("PayrollRun", "key")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("PayrollRun")
+	if err := c.DropIndex("period"); err != nil && !strings.Contains(err.Error(), "index not found") {
+		return updated, err
+	}
+	var runs []models.PayrollRun
+	if err := c.Find(bson.M{"key": bson.M{"$in": []interface{}{"", nil}}}).Select(bson.M{"results": 0}).All(&runs); err != nil {
+		return updated, err
+	}
+	for _, run := range runs {
+		key := models.OrgIDOr(run.OrgID) + ":" + run.Period
+		if err := c.Update(bson.M{"runid": run.RunID}, bson.M{"$set": bson.M{"key": key}}); err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+// SaveArrear Create an arrear to be paid with the payslip of a month ...
+func SaveArrear(arrear *models.Arrear) error {
+	session := GetSession("Arrear", "arrearid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Arrear")
+	return c.Insert(arrear)
+}
+
+// GetArrears list the arrears of an organisation, latest pay month first ...
+func GetArrears(orgID string) ([]models.Arrear, error) {
+	session := GetSession("Arrear", "arrearid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Arrear")
+	var arrears []models.Arrear
+	err := c.Find(bson.M{"orgid": inOrg(orgID)}).Sort("-paymonth", "employeeno").All(&arrears)
+	return arrears, err
+}
+
+// GetArrearsFor list the arrears of a user paid with the payslip of a month ...
+func GetArrearsFor(userID string, month time.Time) ([]models.Arrear, error) {
+	session := GetSession("Arrear", "arrearid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Arrear")
+	var arrears []models.Arrear
+	err := c.Find(bson.M{"userid": userID, "paymonth": inMonth(month)}).Sort("createdon").All(&arrears)
+	return arrears, err
+}
+
+// GetArrear get an arrear by its id ...
+func GetArrear(arrearID string) (models.Arrear, error) {
+	session := GetSession("Arrear", "arrearid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Arrear")
+	var arrear models.Arrear
+	err := c.Find(bson.M{"arrearid": arrearID}).One(&arrear)
+	return arrear, err
+}
+
+// DeleteArrear remove an arrear recorded by mistake ...
+func DeleteArrear(arrearID string) error {
+	session := GetSession("Arrear", "arrearid")
+	session = session.Copy()
+	defer session.Close()
+	c := session.DB(os.Getenv("bc_mongo_db")).C("Arrear")
+	return c.Remove(bson.M{"arrearid": arrearID})
+}
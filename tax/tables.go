@@ -0,0 +1,96 @@
+package tax
+
+import "time"
+
+// Rates for a financial year. Tables exist for FY 2024-25 and FY 2025-26 and payroll
+// for a month outside them fails with an error HR sees on the run. Every year, once
+// the Finance Act of the budget presented in February is passed, add an entry for
+// the year starting that April to years with its slabs, rebate, standard deduction,
+// surcharges and cess, and a case for it in TestIncomeTax. Reuse the tables of the
+// year before only where the Act leaves them unchanged ...
+
+// years Income tax tables by financial year, keyed by the year it starts in ...
+var years = map[int]Year{
+	2024: {
+		Name:     "2024-25",
+		CessRate: 0.04,
+		Old:      oldRegime,
+		New: Regime{
+			Slabs: []Slab{
+				{UpTo: 300000, Rate: 0},
+				{UpTo: 700000, Rate: 0.05},
+				{UpTo: 1000000, Rate: 0.10},
+				{UpTo: 1200000, Rate: 0.15},
+				{UpTo: 1500000, Rate: 0.20},
+				{Rate: 0.30},
+			},
+			StandardDeduction:    75000,
+			RebateLimit:          700000,
+			RebateMax:            25000,
+			RebateMarginalRelief: true,
+			Surcharges:           newRegimeSurcharges,
+		},
+	},
+	2025: {
+		Name:     "2025-26",
+		CessRate: 0.04,
+		Old:      oldRegime,
+		New:      newRegime2025,
+	},
+}
+
+// oldRegime Rates for individuals below 60 under the old regime, unchanged since 2020-21 ...
+var oldRegime = Regime{
+	Slabs: []Slab{
+		{UpTo: 250000, Rate: 0},
+		{UpTo: 500000, Rate: 0.05},
+		{UpTo: 1000000, Rate: 0.20},
+		{Rate: 0.30},
+	},
+	StandardDeduction: 50000,
+	RebateLimit:       500000,
+	RebateMax:         12500,
+	Surcharges: []Surcharge{
+		{Above: 5000000, Rate: 0.10},
+		{Above: 10000000, Rate: 0.15},
+		{Above: 20000000, Rate: 0.25},
+		{Above: 50000000, Rate: 0.37},
+	},
+	AllowsExemptions: true,
+	Limit80C:         150000,
+	Limit80D:         25000,
+	Limit80DSenior:   50000,
+}
+
+// newRegime2025 Rates for the new regime from 2025-26 ...
+var newRegime2025 = Regime{
+	Slabs: []Slab{
+		{UpTo: 400000, Rate: 0},
+		{UpTo: 800000, Rate: 0.05},
+		{UpTo: 1200000, Rate: 0.10},
+		{UpTo: 1600000, Rate: 0.15},
+		{UpTo: 2000000, Rate: 0.20},
+		{UpTo: 2400000, Rate: 0.25},
+		{Rate: 0.30},
+	},
+	StandardDeduction:    75000,
+	RebateLimit:          1200000,
+	RebateMax:            60000,
+	RebateMarginalRelief: true,
+	Surcharges:           newRegimeSurcharges,
+}
+
+// newRegimeSurcharges Surcharge under the new regime is capped at 25% ...
+var newRegimeSurcharges = []Surcharge{
+	{Above: 5000000, Rate: 0.10},
+	{Above: 10000000, Rate: 0.15},
+	{Above: 20000000, Rate: 0.25},
+}
+
+// financialYearStart The year an Indian financial year (April to March) containing date starts in ...
+func financialYearStart(date time.Time) int {
+	if date.Month() < time.April {
+		return date.Year() - 1
+	}
+	return date.Year()
+}
@@ -0,0 +1,187 @@
+package tax
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"bcpayslip/models"
+)
+
+// Tax regimes an employee can opt for ...
+const (
+	RegimeOld = "old"
+	RegimeNew = "new"
+)
+
+type (
+	// Slab Income up to UpTo is taxed at Rate, a zero UpTo is the open top slab ...
+	Slab struct {
+		UpTo float64
+		Rate float64
+	}
+	// Surcharge Rate applied on tax when taxable income is above a threshold ...
+	Surcharge struct {
+		Above float64
+		Rate  float64
+	}
+	// Regime Slabs, deductions and rebate of one tax regime ...
+	Regime struct {
+		Slabs                []Slab
+		StandardDeduction    float64
+		RebateLimit          float64
+		RebateMax            float64
+		RebateMarginalRelief bool
+		Surcharges           []Surcharge
+		AllowsExemptions     bool
+		Limit80C             float64
+		Limit80D             float64
+		Limit80DSenior       float64
+	}
+	// Year Tax tables of a financial year ...
+	Year struct {
+		Name     string
+		CessRate float64
+		Old      Regime
+		New      Regime
+	}
+	// Income Projected annual salary figures of an employee ...
+	Income struct {
+		Gross           float64
+		Basic           float64
+		HRA             float64
+		ProfessionalTax float64
+		Declaration     models.TaxDeclaration
+	}
+	// Result Break up of the annual tax ...
+	Result struct {
+		Taxable   float64
+		Tax       float64
+		Rebate    float64
+		Surcharge float64
+		Cess      float64
+		Total     float64
+	}
+)
+
+// YearFor Tax tables of the financial year a date falls in ...
+func YearFor(date time.Time) (Year, error) {
+	year, ok := years[financialYearStart(date)]
+	if !ok {
+		start := financialYearStart(date)
+		return year, fmt.Errorf("income tax rates for FY %d-%02d are not set up yet, payroll for %s can not be calculated until an administrator adds them", start, (start+1)%100, date.Format("Jan 2006"))
+	}
+	return year, nil
+}
+
+// Regime Tables of the named regime, the new regime is the default ...
+func (y Year) Regime(name string) Regime {
+	if name == RegimeOld {
+		return y.Old
+	}
+	return y.New
+}
+
+// HRAExemption Exempt part of house rent allowance u/s 10(13A), the least of the
+// allowance, rent paid over 10% of basic and 50% (metro) or 40% of basic ...
+func HRAExemption(income Income) float64 {
+	if income.Declaration.RentPaid <= 0 {
+		return 0
+	}
+	share := 0.4
+	if income.Declaration.MetroCity {
+		share = 0.5
+	}
+	exemption := math.Min(income.HRA, income.Declaration.RentPaid-0.1*income.Basic)
+	exemption = math.Min(exemption, share*income.Basic)
+	return math.Max(exemption, 0)
+}
+
+// TaxableIncome Annual income after the deductions the regime allows ...
+func (r Regime) TaxableIncome(income Income) float64 {
+	taxable := income.Gross - r.StandardDeduction
+	if r.AllowsExemptions {
+		declaration := income.Declaration
+		limit80D := r.Limit80D
+		if declaration.SeniorParents {
+			limit80D = r.Limit80DSenior
+		}
+		taxable -= HRAExemption(income)
+		taxable -= income.ProfessionalTax
+		taxable -= math.Min(math.Max(declaration.Section80C, 0), r.Limit80C)
+		taxable -= math.Min(math.Max(declaration.Section80D, 0), r.Limit80D)
+		taxable -= math.Min(math.Max(declaration.Section80DParents, 0), limit80D)
+	}
+	return math.Max(math.Floor(taxable), 0)
+}
+
+// slabTax Tax on taxable income before rebate, surcharge and cess ...
+func (r Regime) slabTax(taxable float64) float64 {
+	var tax, lower float64
+	for _, slab := range r.Slabs {
+		if slab.UpTo == 0 || taxable <= slab.UpTo {
+			return tax + (taxable-lower)*slab.Rate
+		}
+		tax += (slab.UpTo - lower) * slab.Rate
+		lower = slab.UpTo
+	}
+	return tax
+}
+
+// surchargeRate Surcharge rate and the threshold it starts at ...
+func (r Regime) surchargeRate(taxable float64) (float64, float64) {
+	var rate, above float64
+	for _, surcharge := range r.Surcharges {
+		if taxable > surcharge.Above {
+			rate, above = surcharge.Rate, surcharge.Above
+		}
+	}
+	return rate, above
+}
+
+// Compute Annual tax on an income under a regime of the year ...
+func (y Year) Compute(regimeName string, income Income) Result {
+	r := y.Regime(regimeName)
+	result := Result{Taxable: r.TaxableIncome(income)}
+	result.Tax = r.slabTax(result.Taxable)
+	if result.Taxable <= r.RebateLimit {
+		result.Rebate = math.Min(result.Tax, r.RebateMax)
+	} else if r.RebateMarginalRelief && result.Tax > result.Taxable-r.RebateLimit {
+		// tax just above the rebate limit can not exceed the income above it
+		result.Rebate = result.Tax - (result.Taxable - r.RebateLimit)
+	}
+	tax := result.Tax - result.Rebate
+	if rate, above := r.surchargeRate(result.Taxable); rate > 0 {
+		result.Surcharge = tax * rate
+		// marginal relief: tax and surcharge can not grow more than the income above the threshold
+		previousRate, _ := r.surchargeRate(above)
+		limit := r.slabTax(above)*(1+previousRate) + (result.Taxable - above)
+		if tax+result.Surcharge > limit {
+			result.Surcharge = math.Max(limit-tax, 0)
+		}
+	}
+	result.Cess = (tax + result.Surcharge) * y.CessRate
+	result.Total = math.Round(tax + result.Surcharge + result.Cess)
+	return result
+}
+
+// MonthlyTDS Tax to deduct every month, the annual tax spread over twelve months ...
+func MonthlyTDS(month time.Time, income Income) (float64, error) {
+	year, err := YearFor(month)
+	if err != nil {
+		return 0, err
+	}
+	return math.Round(year.Compute(income.Declaration.Regime, income).Total / 12), nil
+}
+
+// OneOffTDS Tax on a one-off taxable payment such as a bonus, the extra annual tax
+// it causes is deducted in full in the month it is paid ...
+func OneOffTDS(month time.Time, income Income, amount float64) (float64, error) {
+	year, err := YearFor(month)
+	if err != nil {
+		return 0, err
+	}
+	without := year.Compute(income.Declaration.Regime, income).Total
+	income.Gross += amount
+	return math.Round(year.Compute(income.Declaration.Regime, income).Total - without), nil
+}
@@ -20,14 +20,65 @@ const ProfileViewTemplate string = "templates/view_profile.html"
 // ProfileEditTemplate ...
 const ProfileEditTemplate string = "templates/edit_profile.html"
 
+// EmployeesTemplate ...
+const EmployeesTemplate string = "templates/employees.html"
+
 // PayslipTemplate ...
 const PayslipTemplate string = "templates/payslip.html"
 
 // PayslipHistoryTemplate ...
 const PayslipHistoryTemplate string = "templates/payslip_history.html"
 
+// PayslipShareTemplate ...
+const PayslipShareTemplate string = "templates/payslip_share.html"
+
 // ApprovalsTemplate ...
 const ApprovalsTemplate string = "templates/approvals.html"
 
 // ApprovalsPayslipTemplate ...
 const ApprovalsPayslipTemplate string = "templates/approvals_payslips.html"
+
+// SalaryStructuresTemplate ...
+const SalaryStructuresTemplate string = "templates/salary_structures.html"
+
+// SalaryStructureTemplate ...
+const SalaryStructureTemplate string = "templates/salary_structure.html"
+
+// PayrollRunsTemplate ...
+const PayrollRunsTemplate string = "templates/payroll_runs.html"
+
+// PayrollRunTemplate ...
+const PayrollRunTemplate string = "templates/payroll_run.html"
+
+// ImportsTemplate ...
+const ImportsTemplate string = "templates/imports.html"
+
+// ImportTemplate ...
+const ImportTemplate string = "templates/import.html"
+
+// RolesTemplate ...
+const RolesTemplate string = "templates/roles.html"
+
+// LoginRejectionsTemplate ...
+const LoginRejectionsTemplate string = "templates/login_rejections.html"
+
+// SessionsTemplate ...
+const SessionsTemplate string = "templates/sessions.html"
+
+// CSRFErrorTemplate ...
+const CSRFErrorTemplate string = "templates/csrf_error.html"
+
+// OrganisationTemplate ...
+const OrganisationTemplate string = "templates/organisation.html"
+
+// VerifyTemplate ...
+const VerifyTemplate string = "templates/verify.html"
+
+// OrganisationsTemplate ...
+const OrganisationsTemplate string = "templates/organisations.html"
+
+// ExchangeRatesTemplate ...
+const ExchangeRatesTemplate string = "templates/exchange_rates.html"
+
+// ArrearsTemplate ...
+const ArrearsTemplate string = "templates/arrears.html"
@@ -1,18 +1,1228 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"image"
+	pngenc "image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"bcpayslip/auth"
+	"bcpayslip/bankexport"
+	"bcpayslip/currency"
 	"bcpayslip/helpers"
+	"bcpayslip/importer"
+	"bcpayslip/layout"
+	"bcpayslip/middlewares"
 	"bcpayslip/models"
+	"bcpayslip/money"
+	"bcpayslip/payroll"
+	"bcpayslip/qrcode"
+	"bcpayslip/statutory"
+	"bcpayslip/store"
+	"bcpayslip/tax"
+	"bcpayslip/templates"
+	"bcpayslip/utils"
+	"bcpayslip/validators"
+
+	"github.com/gorilla/schema"
+	"github.com/markbates/goth"
+	"gopkg.in/mgo.v2/bson"
 )
 
+// failingRenderer A renderer that gives up half way through a PDF ...
+type failingRenderer struct{}
+
+func (failingRenderer) Render(w io.Writer, payslipLayout *layout.Layout, data layout.Data) error {
+	w.Write([]byte("%PDF-1.4 half"))
+	return errors.New("out of paper")
+}
+
+func TestPDFWrite(t *testing.T) {
+	payslip := &models.Payslip{UUID: "write-test", Name: "Asha Rao"}
+	defer os.Remove(helpers.PayslipPDFPath(payslip.UUID))
+	payslipLayout, err := layout.Load(helpers.PayslipLayoutPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = helpers.RenderPayslip(failingRenderer{}, payslipLayout, payslip, models.Organisation{}); err == nil {
+		t.Errorf("failed render reported as written")
+	}
+	if _, err = os.Stat(helpers.PayslipPDFPath(payslip.UUID)); !os.IsNotExist(err) {
+		t.Errorf("half drawn PDF left on disk: %v", err)
+	}
+	pdf, err := helpers.DrawPayslipPDF(payslip, models.Organisation{})
+	if err != nil || !bytes.HasPrefix(pdf, []byte("%PDF")) {
+		t.Fatalf("PDF not drawn in memory: %v", err)
+	}
+	if _, err = os.Stat(helpers.PayslipPDFPath(payslip.UUID)); !os.IsNotExist(err) {
+		t.Errorf("PDF drawn in memory written to disk")
+	}
+	if err = helpers.WritePayslipPDF(payslip.UUID, pdf); err != nil {
+		t.Fatal(err)
+	}
+	written, _ := ioutil.ReadFile(helpers.PayslipPDFPath(payslip.UUID))
+	left, _ := filepath.Glob("media/" + payslip.UUID + "-*.tmp")
+	if !bytes.Equal(written, pdf) || len(left) > 0 {
+		t.Errorf("PDF written %d bytes of %d, temporary files %v", len(written), len(pdf), left)
+	}
+}
+
 func TestPDF(t *testing.T) {
 	payslip := new(models.Payslip)
 	payslip.PayslipID = "123456789012"
-	payslip.GrossAnnualSalary = 660000
-	err := helpers.GeneratePayslipPDF(payslip)
+	payslip.GrossAnnualSalary = money.Rupees(660000)
+	err := helpers.GeneratePayslipPDF(payslip, models.Organisation{Name: "Beautiful Code"})
 	if err != nil {
 		t.Errorf("PDF error: %s", err)
 	}
 }
+
+func TestPDFPassword(t *testing.T) {
+	payslip := &models.Payslip{Name: "Asha Rao", PAN: "abcde1234f", EmployeeNo: "BC042"}
+	if _, err := helpers.PayslipPassword("{PAN:4}{DOB}", payslip); err == nil {
+		t.Errorf("password derived without a date of birth")
+	}
+	payslip.DateOfBirth = time.Date(1990, time.March, 7, 0, 0, 0, 0, time.UTC)
+	password, err := helpers.PayslipPassword("{PAN:4}{DOB}", payslip)
+	if err != nil || password != "ABCD07031990" {
+		t.Errorf("password %q %v", password, err)
+	}
+	if password, _ = helpers.PayslipPassword("{name}-{EMPNO}", payslip); password != "ASHA-BC042" {
+		t.Errorf("password %q", password)
+	}
+	hint := helpers.PasswordHint("{PAN:4}{DOB}")
+	if hint != "the first 4 characters of your PAN in capitals followed by your date of birth as DDMMYYYY" {
+		t.Errorf("hint %q", hint)
+	}
+	for _, rule := range []string{"", "secret", "{PAN", "{SALARY}", "{PAN:x}"} {
+		if helpers.ValidatePasswordRule(rule) == nil {
+			t.Errorf("rule %q accepted", rule)
+		}
+	}
+	payslip.UUID = "protected-test"
+	defer os.Remove(helpers.PayslipPDFPath(payslip.UUID))
+	organisation := models.Organisation{PDFProtection: models.PDFProtection{Enabled: true, PasswordRule: "{PAN:4}{DOB}"}}
+	if err = helpers.GeneratePayslipPDF(payslip, organisation); err != nil {
+		t.Fatal(err)
+	}
+	pdf, _ := ioutil.ReadFile(helpers.PayslipPDFPath(payslip.UUID))
+	if !bytes.Contains(pdf, []byte("/Encrypt")) {
+		t.Errorf("PDF is not encrypted")
+	}
+}
+
+func TestPayslipLink(t *testing.T) {
+	now := time.Now()
+	expires := now.Add(time.Hour).Unix()
+	signature := helpers.SignPayslipLink("uuid-1", expires)
+	if !helpers.VerifyPayslipLink("uuid-1", expires, signature, now) {
+		t.Errorf("valid link rejected")
+	}
+	if helpers.VerifyPayslipLink("uuid-2", expires, signature, now) {
+		t.Errorf("link accepted for another payslip")
+	}
+	if helpers.VerifyPayslipLink("uuid-1", expires, signature, now.Add(2*time.Hour)) {
+		t.Errorf("expired link accepted")
+	}
+}
+
+func TestSalaryStructure(t *testing.T) {
+	earnings, err := payroll.ComputeEarnings(payroll.DefaultStructure(), money.Rupees(50000))
+	if err != nil {
+		t.Fatalf("default structure error: %s", err)
+	}
+	if earnings[0].Amount != money.Rupees(30000) || earnings[3].Amount != money.Rupees(2500) {
+		t.Errorf("default split wrong: %v", earnings)
+	}
+	structure := models.SalaryStructure{
+		Name: "Formula",
+		Components: []models.SalaryComponent{
+			{Name: "Special", Code: "special", Type: models.ComponentFormula, Formula: "gross - basic - hra"},
+			{Name: "HRA", Code: "hra", Type: models.ComponentFormula, Formula: "min(basic * 0.5, 15000)"},
+			{Name: "Basic", Code: "basic", Type: models.ComponentPercentage, Value: 40},
+		},
+	}
+	earnings, err = payroll.ComputeEarnings(structure, money.Rupees(100000))
+	if err != nil {
+		t.Fatalf("formula structure error: %s", err)
+	}
+	if earnings[0].Amount != money.Rupees(45000) || earnings[1].Amount != money.Rupees(15000) || earnings[2].Amount != money.Rupees(40000) {
+		t.Errorf("formula split wrong: %v", earnings)
+	}
+	pasted := models.SalaryStructure{Name: "Pasted", Components: []models.SalaryComponent{
+		{Name: "Basic", Code: "basic", Type: models.ComponentFormula, Formula: "round(gross\t* 0.4)\n"},
+		{Name: "Recovery", Code: "recovery", Type: models.ComponentFormula, Formula: "round(-2.5)"},
+		{Name: "Special", Code: "special", Type: models.ComponentFormula, Formula: "gross -\r\n basic - recovery"},
+	}}
+	earnings, err = payroll.ComputeEarnings(pasted, money.Rupees(10001))
+	if err != nil {
+		t.Fatalf("formula with tabs and line breaks: %s", err)
+	}
+	if earnings[0].Amount != money.Rupees(4000) || earnings[1].Amount != money.Rupees(-3) {
+		t.Errorf("rounded %v, want halves away from zero like money", earnings)
+	}
+	structure.Components[2] = models.SalaryComponent{Name: "Basic", Code: "basic", Type: models.ComponentFormula, Formula: "special"}
+	if _, err = payroll.ComputeEarnings(structure, money.Rupees(100000)); err == nil {
+		t.Errorf("cyclic structure accepted")
+	}
+}
+
+func TestIncomeTax(t *testing.T) {
+	fy2024 := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	fy2025 := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		month  time.Time
+		regime string
+		income tax.Income
+		total  float64
+	}{
+		{"new regime rebate", fy2025, tax.RegimeNew, tax.Income{Gross: 1275000}, 0},
+		{"new regime marginal relief", fy2025, tax.RegimeNew, tax.Income{Gross: 1300000}, 26000},
+		{"new regime 2024-25", fy2024, tax.RegimeNew, tax.Income{Gross: 1075000}, 52000},
+		{"old regime with 80C", fy2025, tax.RegimeOld, tax.Income{
+			Gross: 1000000, Declaration: models.TaxDeclaration{Section80C: 200000},
+		}, 75400},
+		{"surcharge marginal relief", fy2025, tax.RegimeNew, tax.Income{Gross: 5085000}, 1133600},
+	}
+	for _, c := range cases {
+		year, err := tax.YearFor(c.month)
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		if result := year.Compute(c.regime, c.income); result.Total != c.total {
+			t.Errorf("%s: tax %.2f, want %.2f", c.name, result.Total, c.total)
+		}
+	}
+	exemption := tax.HRAExemption(tax.Income{
+		Basic: 600000, HRA: 240000,
+		Declaration: models.TaxDeclaration{RentPaid: 300000, MetroCity: true},
+	})
+	if exemption != 240000 {
+		t.Errorf("HRA exemption %.2f, want 240000", exemption)
+	}
+	if _, err := tax.YearFor(time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC)); err == nil || !strings.Contains(err.Error(), "FY 2026-27") {
+		t.Errorf("missing tables not reported: %v", err)
+	}
+}
+
+func TestStatutoryDeductions(t *testing.T) {
+	february := time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC)
+	c := statutory.Compute("KA", 30000, 50000, february)
+	if c.EmployeePF != 1800 || c.EmployerPension != 1250 || c.EmployerPF != 550 {
+		t.Errorf("provident fund wrong: %+v", c)
+	}
+	if c.ESIApplicable {
+		t.Errorf("ESI applied above the wage limit")
+	}
+	if c.ProfessionalTax != 300 {
+		t.Errorf("Karnataka February professional tax %.2f, want 300", c.ProfessionalTax)
+	}
+	c = statutory.Compute("DL", 9000, 18000, february)
+	if !c.ESIApplicable || c.EmployeeESI != 135 || c.EmployerESI != 585 {
+		t.Errorf("ESI wrong: %+v", c)
+	}
+	if c.PTApplicable {
+		t.Errorf("professional tax applied in Delhi")
+	}
+	september := time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)
+	for month, want := range map[time.Time]float64{september: 930, september.AddDate(0, 6, 0): 930, february: 0} {
+		if c = statutory.Compute("TN", 15000, 9000, month); !c.PTApplicable || c.ProfessionalTax != want {
+			t.Errorf("Tamil Nadu professional tax in %s %.2f, want %.2f", month.Format("Jan"), c.ProfessionalTax, want)
+		}
+	}
+	if annual := statutory.AnnualProfessionalTax("TN", 9000); annual != 1860 {
+		t.Errorf("Tamil Nadu annual professional tax %.2f, want 1860", annual)
+	}
+	if annual := statutory.AnnualProfessionalTax("KA", 50000); annual != 2500 {
+		t.Errorf("Karnataka annual professional tax %.2f, want 2500", annual)
+	}
+}
+
+func TestValidatePayslip(t *testing.T) {
+	payslip := &models.Payslip{
+		Name:               "Jane Doe",
+		Position:           "Engineer",
+		State:              "KA",
+		AccountNo:          "12345678901",
+		IFSCCode:           "HDFC0001234",
+		Month:              time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC),
+		Day:                time.Date(2026, time.September, 30, 0, 0, 0, 0, time.UTC),
+		GrossAnnualSalary:  money.Rupees(50000),
+		AmountReceivedBank: money.Rupees(45000),
+	}
+	if errors := validators.ValidatePayslip(payslip); len(errors) != 0 {
+		t.Errorf("valid payslip rejected: %v", errors)
+	}
+	payslip.Day = time.Date(2026, time.September, 15, 0, 0, 0, 0, time.UTC)
+	if errors := validators.ValidatePayslip(payslip); errors["Day"] == "" {
+		t.Errorf("paid before the end of the pay period")
+	}
+	payslip.Day = time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	if errors := validators.ValidatePayslip(payslip); len(errors) != 0 {
+		t.Errorf("paid after the pay period rejected: %v", errors)
+	}
+	payslip.IFSCCode = "HDFC1001234"
+	payslip.AccountNo = "12AB"
+	payslip.PAN = "ABCDE12345"
+	payslip.Day = time.Date(2026, time.August, 31, 0, 0, 0, 0, time.UTC)
+	payslip.AmountReceivedBank = money.Rupees(60000)
+	payslip.Name = " "
+	errors := validators.ValidatePayslip(payslip)
+	for _, field := range []string{"IFSCCode", "AccountNo", "PAN", "Day", "AmountReceivedBank", "Name"} {
+		if _, ok := errors[field]; !ok {
+			t.Errorf("no error for %s", field)
+		}
+	}
+}
+
+func TestPayrollRun(t *testing.T) {
+	employees := make([]models.Employee, 20)
+	for i := range employees {
+		employees[i] = models.Employee{UserID: string(rune('a' + i)), MonthlyGross: money.Rupees(int64(30000 + 1000*i)), State: "KA", Active: true}
+	}
+	month := time.Date(2024, time.July, 15, 0, 0, 0, 0, time.UTC)
+	results := payroll.RunEach(employees, 4, func(employee models.Employee) models.PayrollResult {
+		payslip, err := payroll.PayslipFor(employee, month, payroll.DefaultStructure())
+		return payroll.ResultOf(employee, payslip, err)
+	})
+	for i, result := range results {
+		if result.UserID != employees[i].UserID {
+			t.Fatalf("result %d out of order: %s", i, result.UserID)
+		}
+		if result.Error != "" || result.Gross != employees[i].MonthlyGross || result.Net != result.Gross-result.Deductions {
+			t.Errorf("result %d wrong: %+v", i, result)
+		}
+	}
+	if payDate := payroll.PayDate(month); payDate.Day() != 31 {
+		t.Errorf("pay date wrong: %s", payDate)
+	}
+}
+
+func TestPayrollImport(t *testing.T) {
+	month := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	sheet := "Emp No,Gross Salary,LOP,Bonus,Bank Credit\n" +
+		"E1,\"60,000\",3,10000,\n" +
+		",,,,\n" +
+		"E1,50000,,,\n" +
+		"E2,abc,31,,\n"
+	records, err := importer.Read(strings.NewReader(sheet), "june.csv")
+	if err != nil {
+		t.Fatalf("csv read error: %s", err)
+	}
+	rows, err := importer.ParseRows(records, month)
+	if err != nil {
+		t.Fatalf("parse error: %s", err)
+	}
+	if len(rows) != 3 || rows[0].Input.Gross != money.Rupees(60000) || rows[0].Input.LOPDays != 3 || len(rows[0].Errors) != 0 {
+		t.Fatalf("first row wrong: %+v", rows)
+	}
+	if rows[1].Line != 4 || len(rows[1].Errors) != 1 {
+		t.Errorf("duplicate row not caught: %+v", rows[1])
+	}
+	if rows[2].Line != 5 || len(rows[2].Errors) != 2 {
+		t.Errorf("bad amounts not caught: %+v", rows[2])
+	}
+	if _, err = importer.ParseRows([][]string{{"Name", "Gross"}}, month); err == nil {
+		t.Errorf("missing employee no column accepted")
+	}
+
+	var xlsx bytes.Buffer
+	archive := zip.NewWriter(&xlsx)
+	parts := map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="June" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/june.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst><si><t>Employee No</t></si><si><r><t>Gro</t></r><r><t>ss</t></r></si></sst>`,
+		"xl/worksheets/june.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>` +
+			`<row r="3"><c r="A3" t="inlineStr"><is><t>E7</t></is></c><c r="C3"><v>45000</v></c></row></sheetData></worksheet>`,
+	}
+	for name, content := range parts {
+		w, _ := archive.Create(name)
+		w.Write([]byte(content))
+	}
+	archive.Close()
+	records, err = importer.Read(&xlsx, "june.xlsx")
+	if err != nil {
+		t.Fatalf("xlsx read error: %s", err)
+	}
+	rows, err = importer.ParseRows(records, month)
+	if err != nil || len(rows) != 1 || rows[0].Line != 3 || rows[0].EmployeeNo != "E7" || rows[0].Input.Gross != money.Rupees(45000) {
+		t.Fatalf("xlsx rows wrong: %+v %v", rows, err)
+	}
+
+	employee := models.Employee{UserID: "u", State: "KA"}
+	payslip, err := payroll.PayslipWith(employee, month, payroll.DefaultStructure(), models.MonthlyInput{Gross: money.Rupees(60000), LOPDays: 3, Bonus: money.Rupees(10000), Reimbursement: money.Rupees(2000)})
+	if err != nil {
+		t.Fatalf("payslip error: %s", err)
+	}
+	if payslip.PaidDays != 27 || payroll.AmountOf(payslip.Earnings, payroll.BasicCode) != money.Rupees(32400) || payroll.Total(payslip.Earnings) != money.Rupees(66000) {
+		t.Errorf("lop or one-off earnings wrong: %v", payslip.Earnings)
+	}
+	if payslip.AmountReceivedBank != money.Rupees(66000)-payroll.Total(payslip.Deductions) {
+		t.Errorf("net pay wrong: %v", payslip.AmountReceivedBank)
+	}
+}
+
+func TestBankExport(t *testing.T) {
+	month := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	payslips := []models.Payslip{
+		{EmployeeNo: "E1", Name: "Asha Rao", AccountNo: "123456789012", IFSCCode: "HDFC0001234", AmountReceivedBank: money.FromFloat(45210.5), Status: models.PayslipApproved},
+		{EmployeeNo: "E2", Name: "Ravi K.", AccountNo: "98765432101", IFSCCode: "SBIN0000456", AmountReceivedBank: money.FromFloat(30000.25), Status: models.PayslipIssued},
+	}
+	control := bankexport.Control{Count: 2, Net: money.FromFloat(75210.75)}
+	batch, err := bankexport.Build(payslips, month, "50200012345678", control)
+	if err != nil {
+		t.Fatalf("build error: %s", err)
+	}
+	if batch.Total() != 7521075 {
+		t.Errorf("total wrong: %d", batch.Total())
+	}
+	var file bytes.Buffer
+	neft, _ := bankexport.Lookup("neft")
+	if err = neft.Write(&file, batch); err != nil {
+		t.Fatalf("neft error: %s", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(file.String(), "\r\n"), "\r\n")
+	if len(lines) != 3 || len(lines[0]) != 127 || len(lines[2]) != 127 {
+		t.Fatalf("neft records wrong: %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "H50200012345678    3006202400000200000000075210.75") {
+		t.Errorf("neft header wrong: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[2], "DSBIN000045698765432101       00000000030000.25RAVI K ") {
+		t.Errorf("neft detail wrong: %q", lines[2])
+	}
+	file.Reset()
+	csvFormat, _ := bankexport.Lookup("csv")
+	csvFormat.Write(&file, batch)
+	if !strings.Contains(file.String(), "E1,Asha Rao,123456789012,HDFC0001234,45210.50,SALARY JUN 2024") {
+		t.Errorf("csv wrong: %s", file.String())
+	}
+	formula := bankexport.Batch{Transfers: []bankexport.Transfer{{EmployeeNo: "@E3", Name: "=HYPERLINK(\"http://x\")", AccountNo: "+1", IFSCCode: "-1", Paise: 100}}}
+	file.Reset()
+	csvFormat.Write(&file, formula)
+	if !strings.Contains(file.String(), "'@E3,\"'=HYPERLINK(\"\"http://x\"\")\",'+1,'-1,1.00") {
+		t.Errorf("csv cells not escaped: %s", file.String())
+	}
+	if _, err = bankexport.Build(payslips, month, "", bankexport.Control{Count: 2, Net: money.FromFloat(45210.5)}); err == nil {
+		t.Errorf("exported with a total other than the approved net pay")
+	}
+	if _, err = bankexport.Build(payslips[:1], month, "", control); err == nil {
+		t.Errorf("exported fewer payslips than approved")
+	}
+	twice := append(payslips, payslips[0])
+	if _, err = bankexport.Build(twice, month, "", bankexport.Control{Count: 3, Net: money.FromFloat(120421.25)}); err == nil || !strings.Contains(err.Error(), "more than one payslip") {
+		t.Errorf("employee paid twice: %v", err)
+	}
+	payslips[1].IFSCCode = "SBIN456"
+	if _, err = bankexport.Build(payslips, month, "", control); err == nil {
+		t.Errorf("invalid IFSC exported")
+	}
+}
+
+func TestUserRoles(t *testing.T) {
+	employee := models.User{Email: "dev@beautifulcode.in"}
+	hr := models.User{Email: "hr@beautifulcode.in", Roles: []string{models.RoleHR}}
+	admin := models.User{Email: "it@beautifulcode.in", Roles: []string{models.RoleAdmin}}
+	if !employee.HasRole(models.RoleEmployee) || employee.HasRole(models.RoleApprover) {
+		t.Errorf("employee roles wrong")
+	}
+	if !hr.HasRole(models.RoleHR) || hr.HasRole(models.RoleApprover) || hr.HasRole(models.RoleAdmin) {
+		t.Errorf("hr roles wrong")
+	}
+	if !admin.HasRole(models.RoleApprover) || !admin.HasRole(models.RoleHR) {
+		t.Errorf("admin should have every role")
+	}
+	os.Setenv("bc_admin_emails", "founder@beautifulcode.in, DEV@beautifulcode.in")
+	defer os.Unsetenv("bc_admin_emails")
+	if !utils.HasRole(employee, models.RoleAdmin) || utils.HasRole(hr, models.RoleAdmin) {
+		t.Errorf("bootstrap admin not applied")
+	}
+}
+
+func TestLoginAllowed(t *testing.T) {
+	os.Setenv("bc_allowed_domains", "beautifulcode.in, Contractors.example.com")
+	os.Setenv("bc_allowed_emails", "auditor@gmail.com")
+	os.Setenv("bc_denied_emails", "former@beautifulcode.in")
+	defer func() {
+		os.Unsetenv("bc_allowed_domains")
+		os.Unsetenv("bc_allowed_emails")
+		os.Unsetenv("bc_denied_emails")
+	}()
+	cases := map[string]bool{
+		"dev@beautifulcode.in":            true,
+		"dev@contractors.example.com":     true,
+		"auditor@gmail.com":               true,
+		"former@beautifulcode.in":         false,
+		"someone@gmail.com":               false,
+		"dev@beautifulcode.in.evil.com":   false,
+		"beautifulcode.in@evil.com":       false,
+		"":                                false,
+		"@beautifulcode.in":               false,
+		"dev@sub.contractors.example.com": false,
+	}
+	for email, want := range cases {
+		if allowed, reason := utils.LoginAllowed(email); allowed != want {
+			t.Errorf("%q allowed %v (%s), want %v", email, allowed, reason, want)
+		}
+	}
+	os.Unsetenv("bc_allowed_domains")
+	if allowed, _ := utils.LoginAllowed("dev@contractors.example.com"); allowed {
+		t.Errorf("default domain should only allow beautifulcode.in")
+	}
+}
+
+func TestOIDCProvider(t *testing.T) {
+	stub := auth.NewStub("", "dev@contractors.example.com")
+	server := httptest.NewServer(stub)
+	defer server.Close()
+	stub.Issuer = server.URL
+	login := func() (goth.User, url.Values, error) {
+		provider := auth.NewOIDC("stub", server.URL, "client", "secret", "http://payslip.test/auth/stub/callback")
+		session, err := provider.BeginAuth("state-1")
+		if err != nil {
+			return goth.User{}, nil, err
+		}
+		authURL, _ := session.GetAuthURL()
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		res, err := client.Get(authURL)
+		if err != nil {
+			return goth.User{}, nil, err
+		}
+		res.Body.Close()
+		callback, _ := url.Parse(res.Header.Get("Location"))
+		if _, err = session.Authorize(provider, callback.Query()); err != nil {
+			return goth.User{}, nil, err
+		}
+		if _, err = session.Authorize(provider, callback.Query()); err == nil {
+			t.Errorf("authorization code used twice")
+		}
+		user, err := provider.FetchUser(session)
+		return user, callback.Query(), err
+	}
+	user, callback, err := login()
+	if err != nil {
+		t.Fatalf("login error: %s", err)
+	}
+	if callback.Get("state") != "state-1" {
+		t.Errorf("state not returned: %v", callback)
+	}
+	if user.Email != "dev@contractors.example.com" || !auth.EmailVerified(user) || auth.UserID(user) != "stub:stub-dev" {
+		t.Errorf("user wrong: %+v", user)
+	}
+	stub.Claims["email_verified"] = "false"
+	if user, _, err = login(); err != nil || auth.EmailVerified(user) {
+		t.Errorf("unverified email accepted: %v", err)
+	}
+	// a tenant of Microsoft Entra, emails are verified only by xms_edov
+	tenant := "72f988bf-86f1-41af-91ab-2d7cd011db47"
+	loginTenant := func() (goth.User, error) {
+		provider := auth.NewOIDC("microsoft", server.URL, "client", "secret", "http://payslip.test/auth/microsoft/callback")
+		provider.Tenant = tenant
+		provider.EmailVerifiedClaim = "xms_edov"
+		session, _ := provider.BeginAuth("state-2")
+		authURL, _ := session.GetAuthURL()
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		res, err := client.Get(authURL)
+		if err != nil {
+			return goth.User{}, err
+		}
+		res.Body.Close()
+		callback, _ := url.Parse(res.Header.Get("Location"))
+		if _, err = session.Authorize(provider, callback.Query()); err != nil {
+			return goth.User{}, err
+		}
+		return provider.FetchUser(session)
+	}
+	stub.Claims["email_verified"] = true
+	stub.Claims["tid"] = "9188040d-6c67-4c5b-b112-36a304b66dad"
+	if _, err = loginTenant(); err == nil {
+		t.Errorf("login from another tenant accepted")
+	}
+	stub.Claims["tid"] = tenant
+	if user, err = loginTenant(); err != nil || auth.EmailVerified(user) {
+		t.Errorf("email without xms_edov accepted: %v", err)
+	}
+	stub.Claims["xms_edov"] = true
+	if user, err = loginTenant(); err != nil || !auth.EmailVerified(user) {
+		t.Errorf("domain verified email refused: %v", err)
+	}
+	stub.Claims["iss"] = "https://login.microsoftonline.com/" + tenant + "/v2.0"
+	if _, err = loginTenant(); err == nil {
+		t.Errorf("id token of another issuer accepted")
+	}
+	delete(stub.Claims, "iss")
+	os.Setenv("bc_auth_providers", "microsoft")
+	defer os.Unsetenv("bc_auth_providers")
+	for _, value := range []string{"common", "organizations", "consumers", "contoso.onmicrosoft.com", ""} {
+		os.Setenv("bc_microsoft_tenant", value)
+		auth.UseProviders("http://payslip.test")
+		if len(auth.Options()) != 0 {
+			t.Errorf("microsoft enabled for tenant %q", value)
+		}
+	}
+	os.Setenv("bc_microsoft_tenant", tenant)
+	defer os.Unsetenv("bc_microsoft_tenant")
+	if auth.UseProviders("http://payslip.test"); len(auth.Options()) != 1 {
+		t.Errorf("microsoft not enabled for a tenant id")
+	}
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	login := time.Date(2024, time.June, 3, 9, 0, 0, 0, time.UTC)
+	session := models.UserSession{CreatedOn: login, LastSeenOn: login.Add(2 * time.Hour), ExpiresOn: login.Add(12 * time.Hour)}
+	if !session.Active(login.Add(2*time.Hour+29*time.Minute), 30*time.Minute) {
+		t.Errorf("session ended while in use")
+	}
+	if session.Active(login.Add(2*time.Hour+31*time.Minute), 30*time.Minute) {
+		t.Errorf("idle session still active")
+	}
+	session.LastSeenOn = login.Add(12*time.Hour - time.Minute)
+	if session.Active(login.Add(12*time.Hour), 30*time.Minute) {
+		t.Errorf("session outlived its maximum age")
+	}
+	os.Setenv("bc_cookie_samesite", "strict")
+	defer os.Unsetenv("bc_cookie_samesite")
+	options := utils.CookieOptions(3600)
+	if !options.HttpOnly || !options.Secure || options.SameSite != http.SameSiteStrictMode || options.MaxAge != 3600 {
+		t.Errorf("cookie options wrong: %+v", options)
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	form := url.Values{"csrf_token": {"token"}}
+	req := httptest.NewRequest("POST", "/home/payroll/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if !middlewares.ValidCSRFToken(req, "token") {
+		t.Errorf("form token refused")
+	}
+	req = httptest.NewRequest("POST", "/home/payroll/", nil)
+	req.Header.Set("X-CSRF-Token", "token")
+	if !middlewares.ValidCSRFToken(req, "token") {
+		t.Errorf("header token refused")
+	}
+	req = httptest.NewRequest("POST", "/home/payroll/", strings.NewReader("csrf_token=other"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if middlewares.ValidCSRFToken(req, "token") {
+		t.Errorf("wrong token accepted")
+	}
+	req = httptest.NewRequest("POST", "/home/payroll/", nil)
+	if middlewares.ValidCSRFToken(req, "") {
+		t.Errorf("missing token accepted")
+	}
+}
+
+func TestFieldEncryption(t *testing.T) {
+	oldKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	newKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+	old, err := store.NewFieldCipher("2023:" + oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := old.Seal("accountno", "50200012345678")
+	if err != nil || !strings.HasPrefix(sealed, "enc:2023:") || strings.Contains(sealed, "50200012345678") {
+		t.Fatalf("account number not encrypted: %s %v", sealed, err)
+	}
+	if _, err = old.Open("ifsccode", sealed); err == nil {
+		t.Errorf("value opened as another field")
+	}
+	if plain, _ := old.Open("accountno", "50200012345678"); plain != "50200012345678" {
+		t.Errorf("plain text value changed: %s", plain)
+	}
+	rotated, err := store.NewFieldCipher("2024:" + newKey + ",2023:" + oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.Current(sealed) {
+		t.Errorf("value of the old key needs no re-encryption")
+	}
+	if plain, err := rotated.Open("accountno", sealed); err != nil || plain != "50200012345678" {
+		t.Errorf("old key value not readable after rotation: %s %v", plain, err)
+	}
+	resealed, _ := rotated.Seal("accountno", "50200012345678")
+	if !rotated.Current(resealed) {
+		t.Errorf("new value not encrypted with the current key: %s", resealed)
+	}
+	if _, err = old.Open("accountno", resealed); err == nil {
+		t.Errorf("value opened without its key")
+	}
+	if _, err = store.NewFieldCipher("2024:c2hvcnQ="); err == nil {
+		t.Errorf("short key accepted")
+	}
+}
+
+func TestPayslipVerification(t *testing.T) {
+	os.Setenv("bc_signing_key", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	defer os.Unsetenv("bc_signing_key")
+	code, err := qrcode.Encode([]byte("https://payslips.example.com/verify/ABCDE12345/"))
+	if err != nil || code.Version != 4 || code.Size != 33 {
+		t.Fatalf("QR code version %+v %v", code, err)
+	}
+	// finder pattern corners are dark, their separators light
+	if !code.Dark(0, 0) || !code.Dark(32, 0) || !code.Dark(0, 32) || code.Dark(7, 7) || code.Dark(25, 7) {
+		t.Errorf("finder patterns misplaced")
+	}
+	if _, err = qrcode.Encode(bytes.Repeat([]byte("x"), 300)); err != qrcode.ErrTooLong {
+		t.Errorf("long data encoded")
+	}
+	if helpers.NormalizeVerificationCode("abcde-1234o<b>") != "ABCDE12340B" {
+		t.Errorf("code %s", helpers.NormalizeVerificationCode("abcde-1234o<b>"))
+	}
+	verificationCode, err := helpers.NewVerificationCode()
+	if other, _ := helpers.NewVerificationCode(); err != nil || len(verificationCode) != 10 || verificationCode == other {
+		t.Fatalf("verification codes %q %q %v", verificationCode, other, err)
+	}
+	payslip := &models.Payslip{UUID: "signed-test", Name: "Asha Rao", VerificationCode: verificationCode}
+	defer os.Remove(helpers.PayslipPDFPath(payslip.UUID))
+	if err = helpers.GeneratePayslipPDF(payslip, models.Organisation{}); err != nil {
+		t.Fatal(err)
+	}
+	pdf, _ := ioutil.ReadFile(helpers.PayslipPDFPath(payslip.UUID))
+	keyID, signature, err := helpers.SignPayslipPDF(pdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = helpers.VerifyPayslipPDF(pdf, keyID, signature); err != nil {
+		t.Errorf("signed PDF rejected: %v", err)
+	}
+	tampered := bytes.Replace(pdf, []byte("Asha Rao"), []byte("Asha Rai"), 1)
+	if bytes.Equal(tampered, pdf) {
+		tampered[len(tampered)/2] ^= 1
+	}
+	if helpers.VerifyPayslipPDF(tampered, keyID, signature) == nil {
+		t.Errorf("tampered PDF accepted")
+	}
+	if helpers.NetPayHash("ABCDE12345", money.Rupees(52000)) == helpers.NetPayHash("ABCDE12345", money.Rupees(52000)+1) {
+		t.Errorf("net pay fingerprints collide")
+	}
+	// the fingerprint can not be worked out without the server key
+	appKey := os.Getenv("bc_app_key")
+	defer os.Setenv("bc_app_key", appKey)
+	fingerprint := helpers.NetPayHash("ABCDE12345", money.Rupees(52000))
+	os.Setenv("bc_app_key", appKey+"other")
+	if helpers.NetPayHash("ABCDE12345", money.Rupees(52000)) == fingerprint {
+		t.Errorf("net pay fingerprint is not keyed")
+	}
+	limiter := utils.NewLimiter(5, time.Hour)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		if !limiter.Allow("code:ABCDE12345", now) {
+			t.Errorf("check %d refused", i+1)
+		}
+	}
+	if limiter.Allow("code:ABCDE12345", now) || !limiter.Allow("code:FGHJK67890", now) {
+		t.Errorf("checks not limited per key")
+	}
+	if !limiter.Allow("code:ABCDE12345", now.Add(time.Hour)) {
+		t.Errorf("checks still refused after the window")
+	}
+}
+
+// pdfText The content streams of a PDF inflated, the text drawn is readable in them ...
+func pdfText(pdf []byte) string {
+	var text strings.Builder
+	for _, part := range bytes.Split(pdf, []byte(">>\nstream\n"))[1:] {
+		end := bytes.Index(part, []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		reader, err := zlib.NewReader(bytes.NewReader(part[:end]))
+		if err != nil {
+			continue
+		}
+		inflated, _ := ioutil.ReadAll(reader)
+		text.Write(inflated)
+	}
+	return text.String()
+}
+
+func TestPayslipLayout(t *testing.T) {
+	if _, err := layout.Load(helpers.PayslipLayoutPath()); err != nil {
+		t.Fatalf("default layout: %v", err)
+	}
+	custom, err := layout.Parse(strings.NewReader(`{"sections": [
+		{"type": "fields", "fields": [
+			{"label": "Name", "value": "{name}"},
+			{"label": "Bonus", "value": "{earning:BONUS}", "optional": true}
+		]},
+		{"type": "panels", "minRows": 2, "panels": [{"title": "Earnings", "list": "earnings"}]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payslip := &models.Payslip{Name: "Asha Rao", Earnings: []models.PayComponent{
+		{Name: "Basic", Code: "BASIC", Amount: money.Rupees(30000)},
+		{Name: "Bonus", Code: payroll.BonusCode, Amount: money.Rupees(5000)},
+		{Name: "HRA", Code: "HRA", Amount: money.Rupees(15000)},
+	}}
+	data := helpers.PayslipData(payslip, models.Organisation{})
+	if value := data.Bind("{name} got {earning:" + payroll.BonusCode + "}"); value != "Asha Rao got 5,000.00" {
+		t.Errorf("bound %q", value)
+	}
+	fields, panels := custom.Sections[0], custom.Sections[1]
+	if fields.HeightFor(data) != 10 || panels.HeightFor(data) != 40 {
+		t.Errorf("heights %v %v", fields.HeightFor(data), panels.HeightFor(data))
+	}
+	payslip.Earnings = payslip.Earnings[:1]
+	data = helpers.PayslipData(payslip, models.Organisation{})
+	if data.Bind("{earning:"+payroll.BonusCode+"}") != "" || panels.HeightFor(data) != 30 {
+		t.Errorf("bonus row shown without a bonus")
+	}
+	if _, err = layout.Parse(strings.NewReader(`{"sections": [{"type": "chart"}]}`)); err == nil {
+		t.Errorf("unknown section type accepted")
+	}
+	payslip.EmployerContributions = []models.PayComponent{
+		{Name: "Employer Provident Fund", Code: payroll.ProvidentFundCode, Amount: money.Rupees(550)},
+		{Name: "Employer Pension Scheme", Code: payroll.PensionCode, Amount: money.Rupees(1250)},
+		{Name: "Employer ESI", Code: payroll.ESICode, Amount: money.FromFloat(585.5)},
+	}
+	data = helpers.PayslipData(payslip, models.Organisation{})
+	if lines := data.Lists["employer_contributions"]; len(lines) != 3 || lines[2].Value != "585.50" || data.Bind("{employer_contributions}") != "2,385.50" {
+		t.Errorf("employer contributions %v total %q", lines, data.Bind("{employer_contributions}"))
+	}
+	pdf, err := helpers.DrawPayslipPDF(payslip, models.Organisation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"Employer Provident Fund", "Employer Pension Scheme", "Employer ESI", "2,385.50"} {
+		if !strings.Contains(pdfText(pdf), line) {
+			t.Errorf("%s not printed on the payslip", line)
+		}
+	}
+	payslip.EmployerContributions = nil
+	if pdf, _ = helpers.DrawPayslipPDF(payslip, models.Organisation{}); strings.Contains(pdfText(pdf), "Employer Contributions") {
+		t.Errorf("employer contributions panel printed without contributions")
+	}
+	payslip.UUID = "layout-test"
+	defer os.Remove(helpers.PayslipPDFPath(payslip.UUID))
+	if err = helpers.RenderPayslip(layout.PDF{}, custom, payslip, models.Organisation{}); err != nil {
+		t.Errorf("render: %v", err)
+	}
+}
+
+func TestOrganisation(t *testing.T) {
+	organisation := models.Organisation{Name: "BC Labs", PAN: "ABCDE1234F", TAN: "ABCD1234E", BrandColor: "blue"}
+	errors := validators.ValidateOrganisation(&organisation)
+	for _, field := range []string{"LegalName", "TAN", "BrandColor"} {
+		if _, ok := errors[field]; !ok {
+			t.Errorf("%s not flagged", field)
+		}
+	}
+	organisation = models.Organisation{
+		OrgID: "labs", Name: "BC Labs", LegalName: "Beautiful Code Labs LLP",
+		Address: "12 MG Road\r\n\r\nBengaluru 560001", PAN: "ABCDE1234F", TAN: "BLRB12345C", BrandColor: "#0B8043",
+	}
+	if errors = validators.ValidateOrganisation(&organisation); len(errors) > 0 {
+		t.Errorf("valid organisation rejected: %v", errors)
+	}
+	if models.OrgIDOr("") != models.DefaultOrgID || models.OrgIDOr("labs") != "labs" {
+		t.Errorf("records without an organisation do not belong to the default one")
+	}
+	payslip := &models.Payslip{}
+	payroll.ApplyEmployee(payslip, models.Employee{Name: "Asha Rao", OrgID: "labs"})
+	if payslip.OrgID != "labs" {
+		t.Errorf("payslip issued by %q", payslip.OrgID)
+	}
+	logo := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	var png bytes.Buffer
+	if err := pngenc.Encode(&png, logo); err != nil {
+		t.Fatal(err)
+	}
+	organisation.Logo = base64.StdEncoding.EncodeToString(png.Bytes())
+	data := helpers.PayslipData(payslip, organisation)
+	if value := data.Bind("{org_name}|{org_address}|{org_tax_ids}|{brand_color}|{accent_color}"); value != "Beautiful Code Labs LLP|12 MG Road, Bengaluru 560001|PAN: ABCDE1234F   TAN: BLRB12345C|#0B8043|#0B8043" {
+		t.Errorf("branding %q", value)
+	}
+	if len(data.Images["logo"]) == 0 {
+		t.Errorf("logo missing")
+	}
+	if data = helpers.PayslipData(payslip, models.Organisation{Name: "Beautiful Code"}); data.Bind("{org_name} {brand_color}") != "Beautiful Code "+models.DefaultBrandColor {
+		t.Errorf("default branding %q", data.Bind("{org_name} {brand_color}"))
+	}
+	payslip.UUID = "organisation-test"
+	defer os.Remove(helpers.PayslipPDFPath(payslip.UUID))
+	if err := helpers.GeneratePayslipPDF(payslip, organisation); err != nil {
+		t.Fatal(err)
+	}
+	pdf, _ := ioutil.ReadFile(helpers.PayslipPDFPath(payslip.UUID))
+	if !bytes.Contains(pdf, []byte("/Subtype /Image")) {
+		t.Errorf("logo missing from the PDF")
+	}
+}
+
+func TestCurrency(t *testing.T) {
+	for _, c := range []struct {
+		amount money.Amount
+		code   string
+		want   string
+	}{
+		{money.FromFloat(12345678.9), "INR", "1,23,45,678.90"},
+		{money.FromFloat(999.5), "", "999.50"},
+		{money.Rupees(-100000), "INR", "-1,00,000.00"},
+		{money.FromFloat(1234567.891), "USD", "1,234,567.89"},
+		{money.FromFloat(1234.5), "EUR", "1.234,50"},
+		{money.FromFloat(1234567.5), "JPY", "1,234,568"},
+		{money.FromFloat(-0.004), "USD", "0.00"},
+	} {
+		if got := currency.Format(c.amount, c.code); got != c.want {
+			t.Errorf("%v %s written %q, want %q", c.amount, c.code, got, c.want)
+		}
+	}
+	employee := models.Employee{UserID: "u1", EmployeeNo: "E9", Name: "Sam Lee", Designation: "Engineer", AccountNo: "GB29NWBK60161331926819", Currency: "GBP"}
+	if errors := validators.ValidateEmployee(&employee); len(errors) > 0 {
+		t.Errorf("overseas account rejected: %v", errors)
+	}
+	employee.Currency = "XYZ"
+	if _, ok := validators.ValidateEmployee(&employee)["Currency"]; !ok {
+		t.Errorf("unknown currency accepted")
+	}
+	employee.Currency = "USD"
+	payslip, err := payroll.PayslipWith(employee, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), payroll.DefaultStructure(), models.MonthlyInput{Gross: money.Rupees(5000), Bonus: money.Rupees(500)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payslip.Currency != "USD" || len(payslip.Deductions) > 0 || payslip.TDS != 0 || payslip.AmountReceivedBank != money.Rupees(5500) {
+		t.Errorf("overseas payslip %s net %v deductions %v", payslip.Currency, payslip.AmountReceivedBank, payslip.Deductions)
+	}
+	payslip.ExchangeRate = 83.12
+	data := helpers.PayslipData(&payslip, models.Organisation{})
+	if value := data.Bind("{currency} {net}|{exchange_rate}|{net_base}"); value != "USD 5,500.00|1 USD = 83.1200 INR|INR 4,57,160.00" {
+		t.Errorf("bound %q", value)
+	}
+	payslip.Status = models.PayslipApproved
+	local := models.Payslip{EmployeeNo: "E1", Name: "Asha Rao", AccountNo: "123456789012", IFSCCode: "HDFC0001234", AmountReceivedBank: money.Rupees(100), Status: models.PayslipApproved}
+	batch, err := bankexport.Build([]models.Payslip{local, payslip}, payslip.Month, "50200012345678", bankexport.Control{Count: 1, Net: money.Rupees(100)})
+	if err != nil || len(batch.Transfers) != 1 {
+		t.Errorf("overseas payslip in the bank file: %v %v", batch.Transfers, err)
+	}
+}
+
+func TestMoney(t *testing.T) {
+	for text, want := range map[string]money.Amount{"45210.5": 4521050, "1,23,456.78": 12345678, "0.105": 11, "-12": -1200, "": 0, ".5": 50} {
+		if amount, err := money.Parse(text); err != nil || amount != want {
+			t.Errorf("%q parsed as %v %v", text, int64(amount), err)
+		}
+	}
+	for _, text := range []string{"12a", "1.2.3", "-", "."} {
+		if _, err := money.Parse(text); err == nil {
+			t.Errorf("%q accepted", text)
+		}
+	}
+	if value := money.Amount(-505).String(); value != "-5.05" {
+		t.Errorf("written %q", value)
+	}
+	earnings, err := payroll.ComputeEarnings(payroll.DefaultStructure(), money.FromFloat(33333.33))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := earnings[len(earnings)-1]; last.Code != payroll.RoundingCode || last.Amount != -1 || payroll.Total(earnings) != money.FromFloat(33333.33) {
+		t.Errorf("earnings do not reconcile to the gross: %v", earnings)
+	}
+	if earnings, _ = payroll.ComputeEarnings(payroll.DefaultStructure(), money.Rupees(50000)); len(earnings) != 4 {
+		t.Errorf("rounding adjustment without rounding: %v", earnings)
+	}
+	var stored struct{ Amount, Whole money.Amount }
+	raw, _ := bson.Marshal(bson.M{"amount": 45210.5, "whole": 45000})
+	if err = bson.Unmarshal(raw, &stored); err != nil || stored.Amount != 4521050 || stored.Whole != 4500000 {
+		t.Errorf("stored rupees read as %v %v %v", stored.Amount, stored.Whole, err)
+	}
+	raw, _ = bson.Marshal(models.PayComponent{Amount: 4521050})
+	var component bson.M
+	if bson.Unmarshal(raw, &component); component["amount"] != 45210.5 {
+		t.Errorf("stored as %v", component["amount"])
+	}
+}
+
+func TestAttendance(t *testing.T) {
+	july := time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		name     string
+		employee models.Employee
+		input    models.MonthlyInput
+		working  float64
+		paid     float64
+	}{
+		{"full month", models.Employee{}, models.MonthlyInput{}, 31, 31},
+		{"unpaid leave", models.Employee{}, models.MonthlyInput{LOPDays: 2.5}, 31, 28.5},
+		{"joiner", models.Employee{DateOfJoining: time.Date(2024, time.July, 16, 0, 0, 0, 0, time.UTC)}, models.MonthlyInput{}, 31, 16},
+		{"leaver", models.Employee{DateOfJoining: time.Date(2020, time.May, 4, 0, 0, 0, 0, time.UTC), DateOfLeaving: time.Date(2024, time.July, 10, 0, 0, 0, 0, time.UTC)}, models.MonthlyInput{LOPDays: 1}, 31, 9},
+		{"left before the month", models.Employee{DateOfLeaving: time.Date(2024, time.June, 30, 0, 0, 0, 0, time.UTC)}, models.MonthlyInput{}, 31, 0},
+		{"six day week", models.Employee{DateOfJoining: time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)}, models.MonthlyInput{WorkingDays: 26, LOPDays: 1}, 26, 25},
+		{"paid days entered", models.Employee{}, models.MonthlyInput{WorkingDays: 26, PaidDays: 20}, 26, 20},
+	} {
+		payslip := models.Payslip{Month: july}
+		payroll.ApplyEmployee(&payslip, c.employee)
+		payroll.ApplyAttendance(&payslip, c.input)
+		if payslip.WorkingDays != c.working || payslip.PaidDays != c.paid {
+			t.Errorf("%s: paid %v of %v days", c.name, payslip.PaidDays, payslip.WorkingDays)
+		}
+	}
+	employee := models.Employee{UserID: "u", State: "KA", DateOfJoining: time.Date(2024, time.July, 20, 0, 0, 0, 0, time.UTC)}
+	payslip, err := payroll.PayslipWith(employee, july, payroll.DefaultStructure(), models.MonthlyInput{Gross: money.FromFloat(33333.33)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payslip.GrossAnnualSalary != money.FromFloat(33333.33) || payroll.Total(payslip.Earnings) != money.FromFloat(33333.33).Times(12.0/31) {
+		t.Errorf("earnings of a joiner %v", payslip.Earnings)
+	}
+	if basic := payroll.AmountOf(payslip.Earnings, payroll.BasicCode); basic != money.Rupees(20000).Times(12.0/31) {
+		t.Errorf("basic not prorated: %v", basic)
+	}
+	data := helpers.PayslipData(&payslip, models.Organisation{})
+	if value := data.Bind("{days_worked}|{lop_days}"); value != "12 of 31|" {
+		t.Errorf("bound %q", value)
+	}
+	payslip.LOPDays = 40
+	if _, ok := validators.ValidatePayslip(&payslip)["LOPDays"]; !ok {
+		t.Errorf("more LOP days than working days accepted")
+	}
+	employee.DateOfLeaving = time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)
+	if _, ok := validators.ValidateEmployee(&employee)["DateOfLeaving"]; !ok {
+		t.Errorf("leaving before joining accepted")
+	}
+}
+
+func TestArrears(t *testing.T) {
+	structure := payroll.DefaultStructure()
+	employee := models.Employee{UserID: "u", State: "KA", MonthlyGross: money.Rupees(100000)}
+	april := time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	if months := payroll.ArrearMonths(april, june.AddDate(0, 0, 14)); len(months) != 3 || !months[2].Equal(june) {
+		t.Errorf("arrear months %v", months)
+	}
+	// the April appraisal to 120000 is applied in June, May had 3.1 days of LOP
+	var paid []models.Payslip
+	for _, input := range []models.MonthlyInput{{Gross: money.Rupees(100000)}, {Gross: money.Rupees(100000), LOPDays: 3.1}} {
+		payslip, err := payroll.PayslipWith(employee, april.AddDate(0, len(paid), 0), structure, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payslip.Status = models.PayslipApproved
+		paid = append(paid, payslip)
+	}
+	arrears, err := payroll.Arrears(structure, money.Rupees(120000), paid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total := payroll.Total(arrears); total != money.Rupees(20000+18000) {
+		t.Errorf("arrears total %s: %v", total, arrears)
+	}
+	for _, arrear := range arrears {
+		if !arrear.Arrear || arrear.Amount <= 0 {
+			t.Errorf("arrear line %+v", arrear)
+		}
+	}
+	if basic := payroll.AmountOf(arrears, payroll.BasicCode); basic <= 0 || basic >= money.Rupees(38000) {
+		t.Errorf("basic arrears %s", basic)
+	}
+	if unchanged, _ := payroll.Arrears(structure, money.Rupees(100000), paid[:1]); len(unchanged) != 0 {
+		t.Errorf("arrears without a change %v", unchanged)
+	}
+	employee.MonthlyGross = money.Rupees(120000)
+	regular, err := payroll.PayslipWith(employee, june, structure, models.MonthlyInput{Gross: money.Rupees(120000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payslip, err := payroll.PayslipWith(employee, june, structure, models.MonthlyInput{Gross: money.Rupees(120000), Arrears: arrears})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payroll.Total(payslip.Earnings) != money.Rupees(120000+38000) || payslip.TDS <= regular.TDS {
+		t.Errorf("june earnings %s tds %s", payroll.Total(payslip.Earnings), payslip.TDS)
+	}
+	if err = payroll.Calculate(&payslip, structure); err != nil || payroll.Total(payslip.Earnings) != money.Rupees(158000) {
+		t.Errorf("arrears lost when calculated again: %v", payslip.Earnings)
+	}
+	data := helpers.PayslipData(&payslip, models.Organisation{})
+	starred := 0
+	for _, line := range data.Lists["earnings"] {
+		if strings.HasSuffix(line.Label, " (*)") {
+			starred++
+		}
+	}
+	if starred != len(arrears) || data.Bind("{arrears}") != "38,000.00" {
+		t.Errorf("arrears marked %d of %d, total %q", starred, len(arrears), data.Bind("{arrears}"))
+	}
+	if data := helpers.PayslipData(&regular, models.Organisation{}); data.Bind("{arrears}") != "" {
+		t.Errorf("payslip without arrears has a total")
+	}
+}
+
+func TestPayslipForm(t *testing.T) {
+	values := url.Values{
+		"Name":                   {"Asha"},
+		"Month":                  {"2024-07-01"},
+		"State":                  {"KA"},
+		"GrossAnnualSalary":      {"50000"},
+		"Earnings.0.Name":        {"Reimbursement"},
+		"Earnings.0.Code":        {payroll.ReimbursementCode},
+		"Earnings.0.Amount":      {"900000"},
+		"Earnings.1.Name":        {"Basic"},
+		"Earnings.1.Code":        {payroll.BasicCode},
+		"Earnings.1.Amount":      {"900000"},
+		"Earnings.1.Arrear":      {"true"},
+		"VerificationCode":       {"CHOSEN"},
+		"AmountReceivedBank":     {"900000"},
+		"Currency":               {"USD"},
+		"Declaration.Regime":     {"new"},
+		"Declaration.Section80C": {"150000"},
+	}
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	decoder.RegisterConverter(time.Time{}, helpers.ConvertFormDate)
+	var form helpers.PayslipForm
+	if err := decoder.Decode(&form, values); err != nil {
+		t.Fatal(err)
+	}
+	var payslip models.Payslip
+	form.Apply(&payslip)
+	if payslip.Name != "Asha" || payslip.GrossAnnualSalary != money.Rupees(50000) || payslip.Declaration.Section80C != 150000 {
+		t.Errorf("entered fields not applied: %+v", payslip)
+	}
+	if len(payslip.Earnings) > 0 || payslip.VerificationCode != "" || payslip.AmountReceivedBank != 0 || payslip.Currency != "" {
+		t.Errorf("fields filled on the server taken from the form: %+v", payslip)
+	}
+	if err := payroll.Calculate(&payslip, payroll.DefaultStructure()); err != nil {
+		t.Fatal(err)
+	}
+	if payroll.Total(payslip.Earnings) != money.Rupees(50000) || payroll.HasArrears(payslip.Earnings) {
+		t.Errorf("earnings %v", payslip.Earnings)
+	}
+}
+
+func TestRenderEscapes(t *testing.T) {
+	payload := "<script>alert(1)</script>"
+	pages := map[string]map[string]interface{}{
+		templates.ApprovalsPayslipTemplate: {"payslip": models.Payslip{Name: payload, Position: payload,
+			Earnings: []models.PayComponent{{Name: payload, Amount: money.Rupees(100), Arrear: true}}}},
+		templates.LoginRejectionsTemplate: {"rejections": []models.LoginRejection{{Email: payload, Provider: payload}}},
+	}
+	for page, data := range pages {
+		data["user"] = models.User{FirstName: payload}
+		tmpl, err := template.ParseFiles(templates.BaseTemplate, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if err = tmpl.Execute(&out, data); err != nil {
+			t.Fatal(page, err)
+		}
+		if strings.Contains(out.String(), payload) || !strings.Contains(out.String(), "&lt;script&gt;") {
+			t.Errorf("%s renders entered values unescaped", page)
+		}
+	}
+}
+
+func TestPayslipHistory(t *testing.T) {
+	for _, c := range []struct{ page, total, previous, next int }{
+		{1, 0, 0, 0}, {1, 10, 0, 0}, {1, 11, 0, 2}, {2, 25, 1, 3}, {3, 25, 2, 0},
+	} {
+		if previous, next := utils.PageNeighbours(c.page, 10, c.total); previous != c.previous || next != c.next {
+			t.Errorf("page %d of %d: pages %d and %d, want %d and %d", c.page, c.total, previous, next, c.previous, c.next)
+		}
+	}
+	month := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+	data := map[string]interface{}{
+		"payslips": []models.Payslip{
+			{UUID: "approved", Month: month, Status: models.PayslipApproved},
+			{UUID: "draft", Month: month, Status: models.PayslipDraft},
+			{UUID: "submitted", Month: month, Status: models.PayslipSubmitted},
+		},
+	}
+	data["previousPage"], data["nextPage"] = utils.PageNeighbours(2, 10, 25)
+	tmpl, err := template.ParseFiles(templates.BaseTemplate, templates.PayslipHistoryTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = tmpl.Execute(&out, data); err != nil {
+		t.Fatal(err)
+	}
+	page := out.String()
+	for _, want := range []string{"/home/payslips/approved/download/", "/home/payslips/draft/submit/", "?page=1", "?page=3"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("history page has no %s", want)
+		}
+	}
+	if strings.Contains(page, "/home/payslips/submitted/") {
+		t.Errorf("payslip waiting for approval can be downloaded or submitted again")
+	}
+}
+
+func TestEmployeePrefill(t *testing.T) {
+	employee := models.Employee{
+		UserID: "u1", Name: "Asha Rao", EmployeeNo: "E1", Designation: "Engineer", Department: "R&D",
+		PAN: "ABCDE1234F", AccountNo: "123456789012", IFSCCode: "HDFC0001234", State: "KA", OrgID: "acme",
+	}
+	var payslip models.Payslip
+	helpers.PayslipForm{
+		Name: "Someone Else", AccountNo: "999", Position: "CEO", GrossAnnualSalary: money.Rupees(50000),
+		Month: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC), Day: time.Date(2024, time.June, 30, 0, 0, 0, 0, time.UTC),
+	}.Apply(&payslip)
+	payroll.ApplyEmployee(&payslip, employee)
+	if payslip.Name != "Asha Rao" || payslip.Position != "Engineer" || payslip.AccountNo != "123456789012" || payslip.EmployeeNo != "E1" {
+		t.Errorf("master record did not override the form: %+v", payslip)
+	}
+	if payslip.OrgID != "acme" || payslip.Currency != currency.Base || payslip.PAN != "ABCDE1234F" {
+		t.Errorf("organisation, currency or PAN not taken from the master record: %+v", payslip)
+	}
+	if errors := validators.ValidatePayslip(&payslip); len(errors) != 0 {
+		t.Errorf("pre-filled payslip rejected: %v", errors)
+	}
+	payroll.ApplyEmployee(&payslip, models.Employee{Name: "New Joiner"})
+	if payslip.OrgID != models.DefaultOrgID || payslip.State != "KA" {
+		t.Errorf("employee without an organisation or state: %q %q", payslip.OrgID, payslip.State)
+	}
+	employee.MonthlyGross = money.Rupees(80000)
+	employee.Declaration = models.TaxDeclaration{Regime: tax.RegimeOld, Section80C: 150000}
+	payroll.ApplyEmployeePay(&payslip, employee)
+	if payslip.GrossAnnualSalary != money.Rupees(80000) || payslip.Declaration.Section80C != 150000 {
+		t.Errorf("gross or declaration of the master record not applied: %v %+v", payslip.GrossAnnualSalary, payslip.Declaration)
+	}
+	payroll.ApplyEmployeePay(&payslip, models.Employee{})
+	if payslip.GrossAnnualSalary != money.Rupees(80000) || payslip.Declaration.Regime != tax.RegimeOld {
+		t.Errorf("empty master record cleared the entered gross or declaration")
+	}
+	employee.State = ""
+	if _, ok := validators.ValidateEmployee(&employee)["State"]; !ok {
+		t.Errorf("rupee employee saved without a state")
+	}
+}
+
+func TestPayslipStatus(t *testing.T) {
+	statuses := []models.PayslipStatus{models.PayslipDraft, models.PayslipSubmitted, models.PayslipApproved, models.PayslipRejected, models.PayslipIssued}
+	allowed := map[models.PayslipStatus][]models.PayslipStatus{
+		models.PayslipDraft:     {models.PayslipSubmitted},
+		models.PayslipSubmitted: {models.PayslipApproved, models.PayslipRejected},
+		models.PayslipApproved:  {models.PayslipIssued},
+	}
+	downloadable := map[models.PayslipStatus]bool{models.PayslipApproved: true, models.PayslipIssued: true}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := false
+			for _, next := range allowed[from] {
+				want = want || next == to
+			}
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s to %s allowed %v, want %v", from, to, got, want)
+			}
+		}
+		if got := from.IsDownloadable(); got != downloadable[from] {
+			t.Errorf("%s downloadable %v, want %v", from, got, downloadable[from])
+		}
+	}
+}
@@ -5,9 +5,6 @@ package urls
 // StaticPath ...
 const StaticPath string = "/static/"
 
-// MediaPath ...
-const MediaPath string = "/media/"
-
 // RootPath ...
 const RootPath string = "/"
 
@@ -31,3 +28,117 @@ const PayslipPath string = HomePath + "payslip/"
 
 // PayslipsPath ...
 const PayslipsPath string = HomePath + "payslips/"
+
+// PayslipSubmitPath ...
+const PayslipSubmitPath string = PayslipsPath + "{uuid}/submit/"
+
+// PayslipDownloadPath ...
+const PayslipDownloadPath string = PayslipsPath + "{uuid}/download/"
+
+// ApprovalsPath ...
+const ApprovalsPath string = HomePath + "approvals/"
+
+// ApprovalPath ...
+const ApprovalPath string = ApprovalsPath + "{uuid}/"
+
+// ApprovePath ...
+const ApprovePath string = ApprovalPath + "approve/"
+
+// RejectPath ...
+const RejectPath string = ApprovalPath + "reject/"
+
+// PayslipSharePath ...
+const PayslipSharePath string = PayslipsPath + "{uuid}/share/"
+
+// SharedPayslipPath ...
+const SharedPayslipPath string = "/payslips/{uuid}/shared/"
+
+// StructuresPath ...
+const StructuresPath string = HomePath + "structures/"
+
+// StructureAssignPath ...
+const StructureAssignPath string = StructuresPath + "assign/"
+
+// StructurePath ...
+const StructurePath string = StructuresPath + "{id}/"
+
+// EmployeesPath ...
+const EmployeesPath string = HomePath + "employees/"
+
+// ProfilePath ...
+const ProfilePath string = HomePath + "profile/{userid}/"
+
+// ProfileEditPath ...
+const ProfileEditPath string = ProfilePath + "edit/"
+
+// PayrollPath ...
+const PayrollPath string = HomePath + "payroll/"
+
+// PayrollRunPath ...
+const PayrollRunPath string = PayrollPath + "{runid}/"
+
+// PayrollPreviewPath ...
+const PayrollPreviewPath string = PayrollRunPath + "preview/"
+
+// PayrollLockPath ...
+const PayrollLockPath string = PayrollRunPath + "lock/"
+
+// PayrollPublishPath ...
+const PayrollPublishPath string = PayrollRunPath + "publish/"
+
+// ImportsPath ...
+const ImportsPath string = HomePath + "imports/"
+
+// ImportPath ...
+const ImportPath string = ImportsPath + "{importid}/"
+
+// ImportCommitPath ...
+const ImportCommitPath string = ImportPath + "commit/"
+
+// ExchangeRatesPath ...
+const ExchangeRatesPath string = HomePath + "rates/"
+
+// ArrearsPath ...
+const ArrearsPath string = HomePath + "arrears/"
+
+// ArrearDeletePath ...
+const ArrearDeletePath string = ArrearsPath + "{arrearid}/delete/"
+
+// PayrollBankExportPath ...
+const PayrollBankExportPath string = PayrollRunPath + "bank/{format}/"
+
+// AdminPath ...
+const AdminPath string = HomePath + "admin/"
+
+// RolesPath ...
+const RolesPath string = AdminPath + "roles/"
+
+// UserRolesPath ...
+const UserRolesPath string = RolesPath + "{userid}/"
+
+// LoginRejectionsPath ...
+const LoginRejectionsPath string = AdminPath + "logins/"
+
+// SessionsPath ...
+const SessionsPath string = HomePath + "sessions/"
+
+// SignOutEverywherePath ...
+const SignOutEverywherePath string = SessionsPath + "revoke/"
+
+// RevokeSessionsPath ...
+const RevokeSessionsPath string = AdminPath + "sessions/{userid}/revoke/"
+
+// OrganisationsPath ...
+const OrganisationsPath string = AdminPath + "organisations/"
+
+// OrganisationPath ...
+const OrganisationPath string = OrganisationsPath + "{orgid}/"
+
+// SwitchOrganisationPath ...
+const SwitchOrganisationPath string = HomePath + "organisation/"
+
+// VerifyFormPath ...
+const VerifyFormPath string = "/verify/"
+
+// VerifyPath ...
+const VerifyPath string = VerifyFormPath + "{code}/"
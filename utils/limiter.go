@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter Counts attempts per key in fixed windows, for public checks whose answer
+// must not be found by trying many values ...
+type Limiter struct {
+	Max      int
+	Window   time.Duration
+	mutex    sync.Mutex
+	attempts map[string]attempts
+}
+
+// attempts Attempts of one key in its current window ...
+type attempts struct {
+	count int
+	until time.Time
+}
+
+// NewLimiter A limiter that allows max attempts per key in every window ...
+func NewLimiter(max int, window time.Duration) *Limiter {
+	return &Limiter{Max: max, Window: window, attempts: make(map[string]attempts)}
+}
+
+// Allow Count an attempt for a key and report whether it is within the limit ...
+func (l *Limiter) Allow(key string, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	// windows that ended are dropped now and then so the map does not grow
+	if len(l.attempts) > 10000 {
+		for k, a := range l.attempts {
+			if !now.Before(a.until) {
+				delete(l.attempts, k)
+			}
+		}
+	}
+	a := l.attempts[key]
+	if !now.Before(a.until) {
+		a = attempts{until: now.Add(l.Window)}
+	}
+	a.count++
+	l.attempts[key] = a
+	return a.count <= l.Max
+}
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"encoding/base32"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bcpayslip/models"
+	"bcpayslip/store"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// SessionName Cookie that carries the id of the login session ...
+const SessionName = "google_gothic_session"
+
+// SessionStore Gorilla sessions store that keeps session values in mongo and only
+// the signed session id in the cookie. A session ends at logout, when it was not
+// used for IdleTimeout, MaxAge after login, or when it is revoked by deleting it ...
+type SessionStore struct {
+	Codecs      []securecookie.Codec
+	Options     *sessions.Options
+	IdleTimeout time.Duration
+	MaxAge      time.Duration
+}
+
+var (
+	sessionStore     *SessionStore
+	sessionStoreOnce sync.Once
+)
+
+// envInt Positive number from the environment or a default ...
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// CookieOptions Cookie flags from the environment, cookies are always HttpOnly and
+// Secure unless bc_cookie_secure is false or the app runs in development. SameSite
+// is bc_cookie_samesite, lax by default so logins coming back from a provider work ...
+func CookieOptions(maxAge int) *sessions.Options {
+	options := &sessions.Options{
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   os.Getenv("bc_env") != "development",
+		SameSite: http.SameSiteLaxMode,
+	}
+	if secure, err := strconv.ParseBool(os.Getenv("bc_cookie_secure")); err == nil {
+		options.Secure = secure
+	}
+	switch strings.ToLower(os.Getenv("bc_cookie_samesite")) {
+	case "strict":
+		options.SameSite = http.SameSiteStrictMode
+	case "none":
+		options.SameSite = http.SameSiteNoneMode
+		options.Secure = true
+	}
+	return options
+}
+
+// NewSessionStore Session store with timeouts, keys are used as by sessions.NewCookieStore ...
+func NewSessionStore(idleTimeout time.Duration, maxAge time.Duration, keyPairs ...[]byte) *SessionStore {
+	s := &SessionStore{
+		Codecs:      securecookie.CodecsFromPairs(keyPairs...),
+		Options:     CookieOptions(int(maxAge.Seconds())),
+		IdleTimeout: idleTimeout,
+		MaxAge:      maxAge,
+	}
+	for _, codec := range s.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(int(maxAge.Seconds()))
+		}
+	}
+	return s
+}
+
+// GetSessionStore The session store of the app, sessions end after
+// bc_session_idle_minutes without use (30 by default) and at the latest
+// bc_session_max_hours after login (12 by default) ...
+func GetSessionStore() *SessionStore {
+	sessionStoreOnce.Do(func() {
+		sessionStore = NewSessionStore(
+			time.Duration(envInt("bc_session_idle_minutes", 30))*time.Minute,
+			time.Duration(envInt("bc_session_max_hours", 12))*time.Hour,
+			[]byte(os.Getenv("bc_app_key")),
+		)
+	})
+	return sessionStore
+}
+
+// Get Session of the request, shared by every call during the request ...
+func (s *SessionStore) Get(req *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(req).Get(s, name)
+}
+
+// New Load the session in the cookie, a new empty session is returned when there
+// is none or it ended ...
+func (s *SessionStore) New(req *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	options := *s.Options
+	session.Options = &options
+	session.IsNew = true
+	cookie, err := req.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var sessionID string
+	if err = securecookie.DecodeMulti(name, cookie.Value, &sessionID, s.Codecs...); err != nil {
+		return session, err
+	}
+	record, err := store.GetUserSession(sessionID)
+	if err != nil {
+		// revoked, or removed after it expired
+		return session, nil
+	}
+	now := time.Now()
+	if !record.Active(now, s.IdleTimeout) {
+		if err = store.DeleteUserSession(sessionID); err != nil {
+			log.Println(err)
+		}
+		return session, nil
+	}
+	if err = securecookie.DecodeMulti(name, record.Values, &session.Values, s.Codecs...); err != nil {
+		return session, err
+	}
+	session.ID = sessionID
+	session.IsNew = false
+	if now.Sub(record.LastSeenOn) > time.Minute {
+		if err = store.TouchUserSession(sessionID, now); err != nil {
+			log.Println(err)
+		}
+	}
+	return session, nil
+}
+
+// Save Store the session values and set the cookie, a negative MaxAge ends the session ...
+func (s *SessionStore) Save(req *http.Request, res http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := store.DeleteUserSession(session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(res, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+	values, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+		if err = store.DeleteExpiredUserSessions(now); err != nil {
+			log.Println(err)
+		}
+	}
+	userID, _ := session.Values["userid"].(string)
+	record := models.UserSession{
+		SessionID:  session.ID,
+		UserID:     userID,
+		Values:     values,
+		RemoteAddr: req.RemoteAddr,
+		UserAgent:  req.UserAgent(),
+		CreatedOn:  now,
+		LastSeenOn: now,
+		ExpiresOn:  now.Add(s.MaxAge),
+	}
+	if err = store.SaveUserSession(&record); err != nil {
+		return err
+	}
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(res, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// StartSession Log a user in with a fresh session id, so an id handed out before
+// login can not be used to ride on the login ...
+func StartSession(res http.ResponseWriter, req *http.Request, userID string) error {
+	session, _ := GetValidSession(req)
+	if session.ID != "" {
+		if err := store.DeleteUserSession(session.ID); err != nil {
+			log.Println(err)
+		}
+		session.ID = ""
+	}
+	session.Values = map[interface{}]interface{}{"userid": userID}
+	return session.Save(req, res)
+}
+
+// EndSession Log out of the session of the request ...
+func EndSession(res http.ResponseWriter, req *http.Request) error {
+	session, _ := GetValidSession(req)
+	session.Options.MaxAge = -1
+	return session.Save(req, res)
+}
@@ -1,11 +1,12 @@
 package utils
 
 import (
+	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
-	"text/template"
 
 	"bcpayslip/models"
 	"bcpayslip/store"
@@ -18,8 +19,7 @@ import (
 
 // GetValidSession Returns a valid authenticated user session ...
 func GetValidSession(req *http.Request) (*sessions.Session, error) {
-	sessStore := sessions.NewCookieStore([]byte(os.Getenv("bc_app_key")))
-	return sessions.Store.Get(sessStore, req, "google_gothic_session")
+	return GetSessionStore().Get(req, SessionName)
 }
 
 // CustomTemplateExecute Append common templates and data structs and execute template ...
@@ -27,15 +27,33 @@ func CustomTemplateExecute(res http.ResponseWriter, req *http.Request, templateN
 	t, _ := template.ParseFiles(templates.BaseTemplate, templateName)
 	if len(data) == 0 {
 		data = make(map[string]interface{})
-		data["user"], _ = store.GetUser(context.Get(req, "userid").(string))
-	} else {
-		data["user"], _ = store.GetUser(context.Get(req, "userid").(string))
+	}
+	user, _ := store.GetUser(context.Get(req, "userid").(string))
+	data["user"] = user
+	data["isApprover"] = HasRole(user, models.RoleApprover)
+	data["isHR"] = HasRole(user, models.RoleHR)
+	data["isAdmin"] = HasRole(user, models.RoleAdmin)
+	data["csrfToken"] = context.Get(req, "csrf")
+	data["orgID"] = CurrentOrgID(req)
+	if data["isApprover"] == true || data["isHR"] == true {
+		organisations, err := store.GetOrganisations()
+		if err != nil {
+			log.Println(err)
+		}
+		data["switchOrganisations"] = organisations
 	}
 	if err := t.Execute(res, data); err != nil {
 		log.Println(err)
 	}
 }
 
+// CurrentOrgID The organisation HR and approvers are working in, picked in their
+// session, the default organisation until they pick another ...
+func CurrentOrgID(req *http.Request) string {
+	orgID, _ := context.Get(req, "orgid").(string)
+	return models.OrgIDOr(orgID)
+}
+
 // AddParamsToURL Add params to url using a splice of models.kwargs struct ...
 func AddParamsToURL(url string, args []models.Kwargs) string {
 	for _, arg := range args {
@@ -43,3 +61,73 @@ func AddParamsToURL(url string, args []models.Kwargs) string {
 	}
 	return url
 }
+
+// PageNeighbours The previous and next page of a listing with total items, zero
+// when there is no such page ...
+func PageNeighbours(page int, perPage int, total int) (int, int) {
+	previous, next := 0, 0
+	if page > 1 {
+		previous = page - 1
+	}
+	if page*perPage < total {
+		next = page + 1
+	}
+	return previous, next
+}
+
+// RedirectWithMessage Redirect to a path with a toast message for base template ...
+func RedirectWithMessage(res http.ResponseWriter, req *http.Request, path string, message string) {
+	http.Redirect(res, req, path+"?m="+url.QueryEscape(message), http.StatusSeeOther)
+}
+
+// HasRole Checks if the user has a role, the bootstrap admins configured as comma
+// separated emails in bc_admin_emails have every role so they can grant the rest ...
+func HasRole(user models.User, role string) bool {
+	if emailInList(user.Email, os.Getenv("bc_admin_emails")) {
+		return true
+	}
+	return user.HasRole(role)
+}
+
+// defaultLoginDomain Domain allowed to log in when bc_allowed_domains is not set ...
+const defaultLoginDomain = "beautifulcode.in"
+
+// LoginAllowed Checks an email against the login policy and says why it was turned
+// away. Emails in bc_denied_emails are always refused, emails in bc_allowed_emails
+// are always let in and everyone else needs an email on one of the comma separated
+// domains in bc_allowed_domains ...
+func LoginAllowed(email string) (bool, string) {
+	at := strings.LastIndex(email, "@")
+	if at < 1 || at == len(email)-1 {
+		return false, "no valid email address"
+	}
+	if emailInList(email, os.Getenv("bc_denied_emails")) {
+		return false, "email is denied"
+	}
+	if emailInList(email, os.Getenv("bc_allowed_emails")) {
+		return true, ""
+	}
+	domains := os.Getenv("bc_allowed_domains")
+	if strings.TrimSpace(domains) == "" {
+		domains = defaultLoginDomain
+	}
+	for _, domain := range strings.Split(domains, ",") {
+		if strings.EqualFold(strings.TrimSpace(domain), email[at+1:]) {
+			return true, ""
+		}
+	}
+	return false, "domain is not allowed"
+}
+
+// emailInList Case insensitive lookup of an email in a comma separated list ...
+func emailInList(email string, list string) bool {
+	if email == "" {
+		return false
+	}
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), email) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package validators
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"bcpayslip/currency"
+	"bcpayslip/models"
+)
+
+var (
+	ifscPattern    = regexp.MustCompile(`^[A-Z]{4}0[A-Z0-9]{6}$`)
+	panPattern     = regexp.MustCompile(`^[A-Z]{5}[0-9]{4}[A-Z]$`)
+	accountPattern = regexp.MustCompile(`^[0-9]{9,18}$`)
+	uanPattern     = regexp.MustCompile(`^[0-9]{12}$`)
+	ibanPattern    = regexp.MustCompile(`^[A-Z0-9]{6,34}$`)
+	tanPattern     = regexp.MustCompile(`^[A-Z]{4}[0-9]{5}[A-Z]$`)
+	colorPattern   = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+)
+
+// IsValidIFSC Four letter bank code, a zero and a six character branch code ...
+func IsValidIFSC(ifsc string) bool {
+	return ifscPattern.MatchString(ifsc)
+}
+
+// IsValidPAN Five letters, four digits and a check letter ...
+func IsValidPAN(pan string) bool {
+	return panPattern.MatchString(pan)
+}
+
+// IsValidAccountNo Indian bank account numbers are 9 to 18 digits ...
+func IsValidAccountNo(accountNo string) bool {
+	return accountPattern.MatchString(accountNo)
+}
+
+// IsValidForeignAccountNo Accounts abroad are IBANs or local numbers of up to 34
+// letters and digits ...
+func IsValidForeignAccountNo(accountNo string) bool {
+	return ibanPattern.MatchString(accountNo)
+}
+
+// IsValidUAN Universal account numbers of provident fund are 12 digits ...
+func IsValidUAN(uan string) bool {
+	return uanPattern.MatchString(uan)
+}
+
+// IsValidTAN Tax deduction account numbers are four letters, five digits and a
+// check letter ...
+func IsValidTAN(tan string) bool {
+	return tanPattern.MatchString(tan)
+}
+
+// IsValidColor Colours are given as #rrggbb ...
+func IsValidColor(color string) bool {
+	return colorPattern.MatchString(color)
+}
+
+// Errors Error message by form field name ...
+type Errors map[string]string
+
+// add Keep the first error of a field ...
+func (e Errors) add(field string, message string) {
+	if _, ok := e[field]; !ok {
+		e[field] = message
+	}
+}
+
+// required Flag empty text fields ...
+func (e Errors) required(fields map[string]string) {
+	for field, value := range fields {
+		if strings.TrimSpace(value) == "" {
+			e.add(field, "This field is required")
+		}
+	}
+}
+
+// bankAccount Check the account pay is credited to, accounts abroad have no IFSC
+// code and numbers of their own ...
+func (e Errors) bankAccount(code string, accountNo string, ifsc string) {
+	if _, ok := currency.Lookup(code); !ok {
+		e.add("Currency", "Pick a currency")
+	}
+	if currency.IsForeign(code) {
+		if accountNo != "" && !IsValidForeignAccountNo(accountNo) {
+			e.add("AccountNo", "Account number must be 6 to 34 letters and digits")
+		}
+		return
+	}
+	e.required(map[string]string{"IFSCCode": ifsc})
+	if accountNo != "" && !IsValidAccountNo(accountNo) {
+		e.add("AccountNo", "Account number must be 9 to 18 digits")
+	}
+	if ifsc != "" && !IsValidIFSC(ifsc) {
+		e.add("IFSCCode", "IFSC code looks like ABCD0123456")
+	}
+}
+
+// ValidatePayslip Check the payslip details entered in the form, returns no errors when valid ...
+func ValidatePayslip(payslip *models.Payslip) Errors {
+	errors := make(Errors)
+	errors.required(map[string]string{
+		"Name":      payslip.Name,
+		"AccountNo": payslip.AccountNo,
+		"Position":  payslip.Position,
+	})
+	if !currency.IsForeign(payslip.Currency) {
+		errors.required(map[string]string{"State": payslip.State})
+	}
+	errors.bankAccount(payslip.Currency, payslip.AccountNo, payslip.IFSCCode)
+	if payslip.PAN != "" && !IsValidPAN(payslip.PAN) {
+		errors.add("PAN", "PAN looks like ABCDE1234F")
+	}
+	if payslip.Month.IsZero() {
+		errors.add("Month", "This field is required")
+	}
+	if payslip.Day.IsZero() {
+		errors.add("Day", "This field is required")
+	}
+	if !payslip.Month.IsZero() && !payslip.Day.IsZero() {
+		// salaries are paid for a period once it is over, on its last day at the earliest
+		periodEnd := time.Date(payslip.Month.Year(), payslip.Month.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+		payDay := time.Date(payslip.Day.Year(), payslip.Day.Month(), payslip.Day.Day(), 0, 0, 0, 0, time.UTC)
+		if payDay.Before(periodEnd) {
+			errors.add("Day", "Pay date can not be before the end of the pay period, "+periodEnd.Format("02 Jan 2006"))
+		}
+	}
+	if payslip.GrossAnnualSalary <= 0 {
+		errors.add("GrossAnnualSalary", "Gross salary must be more than zero")
+	}
+	if payslip.AmountReceivedBank < 0 {
+		errors.add("AmountReceivedBank", "Amount can not be negative")
+	}
+	if payslip.AmountReceivedBank > payslip.GrossAnnualSalary {
+		errors.add("AmountReceivedBank", "Net pay can not be more than the gross salary")
+	}
+	if payslip.WorkingDays < 0 || payslip.WorkingDays > 31 {
+		errors.add("WorkingDays", "Working days must be between 1 and 31")
+	}
+	if payslip.LOPDays < 0 {
+		errors.add("LOPDays", "LOP days can not be negative")
+	} else if payslip.WorkingDays > 0 && payslip.LOPDays > payslip.WorkingDays {
+		errors.add("LOPDays", "LOP days can not be more than the working days")
+	}
+	declaration := payslip.Declaration
+	for field, amount := range map[string]float64{
+		"Declaration.Section80C":        declaration.Section80C,
+		"Declaration.Section80D":        declaration.Section80D,
+		"Declaration.Section80DParents": declaration.Section80DParents,
+		"Declaration.RentPaid":          declaration.RentPaid,
+	} {
+		if amount < 0 {
+			errors.add(field, "Amount can not be negative")
+		}
+	}
+	return errors
+}
+
+// ValidateEmployee Check an employee record entered by HR ...
+func ValidateEmployee(employee *models.Employee) Errors {
+	errors := make(Errors)
+	errors.required(map[string]string{
+		"EmployeeNo":  employee.EmployeeNo,
+		"Name":        employee.Name,
+		"Designation": employee.Designation,
+		"AccountNo":   employee.AccountNo,
+	})
+	if !currency.IsForeign(employee.Currency) {
+		errors.required(map[string]string{"State": employee.State})
+	}
+	errors.bankAccount(employee.Currency, employee.AccountNo, employee.IFSCCode)
+	if employee.PAN != "" && !IsValidPAN(employee.PAN) {
+		errors.add("PAN", "PAN looks like ABCDE1234F")
+	}
+	if employee.UAN != "" && !IsValidUAN(employee.UAN) {
+		errors.add("UAN", "UAN must be 12 digits")
+	}
+	if employee.MonthlyGross < 0 {
+		errors.add("MonthlyGross", "Amount can not be negative")
+	}
+	if employee.Active && employee.MonthlyGross == 0 {
+		errors.add("MonthlyGross", "Active employees need a monthly gross salary for payroll runs")
+	}
+	if !employee.DateOfLeaving.IsZero() && employee.DateOfLeaving.Before(employee.DateOfJoining) {
+		errors.add("DateOfLeaving", "Date of leaving can not be before the date of joining")
+	}
+	return errors
+}
+
+// ValidateOrganisation Check the details and branding of an organisation entered
+// by an admin ...
+func ValidateOrganisation(organisation *models.Organisation) Errors {
+	errors := make(Errors)
+	errors.required(map[string]string{
+		"Name":      organisation.Name,
+		"LegalName": organisation.LegalName,
+	})
+	if organisation.PAN != "" && !IsValidPAN(organisation.PAN) {
+		errors.add("PAN", "PAN looks like ABCDE1234F")
+	}
+	if organisation.TAN != "" && !IsValidTAN(organisation.TAN) {
+		errors.add("TAN", "TAN looks like ABCD12345E")
+	}
+	if organisation.DebitAccount != "" && !IsValidAccountNo(organisation.DebitAccount) {
+		errors.add("DebitAccount", "Account number must be 9 to 18 digits")
+	}
+	for field, color := range map[string]string{"BrandColor": organisation.BrandColor, "AccentColor": organisation.AccentColor} {
+		if color != "" && !IsValidColor(color) {
+			errors.add(field, "Colours look like #1AA2FB")
+		}
+	}
+	return errors
+}